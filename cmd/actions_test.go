@@ -2,15 +2,23 @@ package cmd
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"os"
+	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/nguyenanhhao221/pScan/history"
+	"github.com/nguyenanhhao221/pScan/policy"
+	"github.com/nguyenanhhao221/pScan/report"
 	"github.com/nguyenanhhao221/pScan/scan"
 )
 
@@ -39,6 +47,17 @@ func setUpFile(t *testing.T, initList bool, hosts []string) string {
 	return tempFile.Name()
 }
 
+// latencyLine and portLatency match the latency summary of a host and the latency
+// of a port in the text output, they differ from one run to the next
+var (
+	latencyLine = regexp.MustCompile(`(?m)^\tlatency: .*\n`)
+	portLatency = regexp.MustCompile(`(?m) \([0-9.]+[a-zµ]+\)$`)
+)
+
+func withoutLatency(out string) string {
+	return portLatency.ReplaceAllString(latencyLine.ReplaceAllString(out, ""), "")
+}
+
 func TestActions(t *testing.T) {
 	hosts := []string{"host1", "host2", "host3"}
 	testCases := []struct {
@@ -88,6 +107,103 @@ func TestActions(t *testing.T) {
 	}
 }
 
+func TestListExpandAction(t *testing.T) {
+	hostsFile := setUpFile(t, true, []string{"host1", "10.0.0.0/31", "192.168.1.10-11"})
+	var out bytes.Buffer
+
+	if err := listExpandAction(&out, hostsFile, 0); err != nil {
+		t.Fatalf("Expect no error, got: %v\n", err)
+	}
+
+	exp := "10.0.0.0/31:\n\t10.0.0.0\n\t10.0.0.1\nhost1\n192.168.1.10-11:\n\t192.168.1.10\n\t192.168.1.11\n"
+	if diff := cmp.Diff(exp, out.String()); diff != "" {
+		t.Errorf("%s mismatch (-want +got):\n%s", t.Name(), diff)
+	}
+
+	out.Reset()
+	if err := listExpandAction(&out, hostsFile, 1); !errors.Is(err, scan.ErrTargetTooLarge) {
+		t.Errorf("Expect error %q, got %v", scan.ErrTargetTooLarge, err)
+	}
+}
+
+func TestAddInfoAction(t *testing.T) {
+	hostsFile := setUpFile(t, true, []string{"host1"})
+	var out bytes.Buffer
+
+	info := scan.HostInfo{Ports: "22,443", Tags: []string{"web", "prod"}, Owner: "alice", Notes: "public frontend"}
+	if err := addInfoAction(&out, hostsFile, []string{"web1"}, info, scan.DefaultMaxExpand); err != nil {
+		t.Fatalf("Expect no error, got: %v\n", err)
+	}
+
+	out.Reset()
+	if err := listAction(&out, hostsFile, nil); err != nil {
+		t.Fatalf("Expect no error, got: %v\n", err)
+	}
+
+	exp := "host1\nweb1 ports=22,443 tags=web,prod owner=alice notes=\"public frontend\"\n"
+	if diff := cmp.Diff(exp, out.String()); diff != "" {
+		t.Errorf("%s mismatch (-want +got):\n%s", t.Name(), diff)
+	}
+
+	// Hosts already in the list get the new attributes merged into theirs
+	out.Reset()
+	update := scan.HostInfo{Group: "frontend", Tags: []string{"prod", "eu"}}
+	if err := addInfoAction(&out, hostsFile, []string{"host1", "web1"}, update, scan.DefaultMaxExpand); err != nil {
+		t.Fatalf("Expect no error, got: %v\n", err)
+	}
+	if exp := "Updated host: host1\nUpdated host: web1\n"; out.String() != exp {
+		t.Errorf("Expect %q, got %q", exp, out.String())
+	}
+
+	out.Reset()
+	if err := listAction(&out, hostsFile, nil); err != nil {
+		t.Fatalf("Expect no error, got: %v\n", err)
+	}
+	exp = "host1 group=frontend tags=prod,eu\nweb1 ports=22,443 group=frontend tags=web,prod,eu owner=alice notes=\"public frontend\"\n"
+	if diff := cmp.Diff(exp, out.String()); diff != "" {
+		t.Errorf("%s mismatch (-want +got):\n%s", t.Name(), diff)
+	}
+
+	// Without attributes there is nothing to add to an existing host
+	if err := addInfoAction(&out, hostsFile, []string{"host1"}, scan.HostInfo{}, scan.DefaultMaxExpand); !errors.Is(err, scan.ErrExists) {
+		t.Errorf("Expect error %q, got %v", scan.ErrExists, err)
+	}
+}
+
+func TestAddInfoActionInvalid(t *testing.T) {
+	testCases := []struct {
+		name   string
+		host   string
+		expErr error
+	}{
+		{"InvalidCIDR", "10.0.0.0/33", scan.ErrInvalidTarget},
+		{"TooLarge", "10.0.0.0/8", scan.ErrTargetTooLarge},
+		{"InvalidRange", "10.0.0.9-10.0.0.1", scan.ErrInvalidTarget},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			hostsFile := setUpFile(t, true, []string{"host1"})
+			var out bytes.Buffer
+
+			// Nothing is added when any of the hosts is invalid
+			err := addInfoAction(&out, hostsFile, []string{"web1", tc.host}, scan.HostInfo{}, scan.DefaultMaxExpand)
+			if !errors.Is(err, tc.expErr) {
+				t.Fatalf("Expect error %q, got %v", tc.expErr, err)
+			}
+			if out.Len() != 0 {
+				t.Errorf("Expect no output, got %q", out.String())
+			}
+			if err := listAction(&out, hostsFile, nil); err != nil {
+				t.Fatalf("Expect no error, got: %v\n", err)
+			}
+			if exp := "host1\n"; out.String() != exp {
+				t.Errorf("Expect %q, got %q", exp, out.String())
+			}
+		})
+	}
+}
+
 func TestScanAction(t *testing.T) {
 	hosts := []string{"localhost", "invalidhost"}
 	ports := []int{}
@@ -119,7 +235,7 @@ func TestScanAction(t *testing.T) {
 	}
 
 	var out bytes.Buffer
-	err := scanAction(&out, tmpFile, ports)
+	err := scanAction(context.Background(), &out, scanConfig{hostsFile: tmpFile, ports: ports})
 	if err != nil {
 		t.Errorf("Expect not error got %q", err)
 	}
@@ -132,12 +248,329 @@ func TestScanAction(t *testing.T) {
 	expectPrintOut += fmt.Sprintln("invalidhost: Host not found")
 	expectPrintOut += fmt.Sprintln()
 
+	got := withoutLatency(out.String())
+	if diff := cmp.Diff(expectPrintOut, got); diff != "" {
+		t.Errorf("%s mismatch (-want +got):\n%s", t.Name(), diff)
+	}
+}
+
+func TestParseRate(t *testing.T) {
+	testCases := []struct {
+		spec   string
+		exp    float64
+		expErr error
+	}{
+		{spec: "", exp: 0},
+		{spec: "100/s", exp: 100},
+		{spec: "2.5", exp: 2.5},
+		{spec: "0/s", expErr: errInvalidRate},
+		{spec: "fast", expErr: errInvalidRate},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.spec, func(t *testing.T) {
+			rate, err := parseRate(tc.spec)
+			if !errors.Is(err, tc.expErr) {
+				t.Fatalf("Expect error %v, got %v", tc.expErr, err)
+			}
+			if rate != tc.exp {
+				t.Errorf("Expect rate %v, got %v", tc.exp, rate)
+			}
+		})
+	}
+}
+
+func TestProgressLine(t *testing.T) {
+	var out bytes.Buffer
+	l := &progressLine{out: &out}
+
+	l.update(scan.Progress{Done: 50, Total: 200, Open: 3, Elapsed: 5 * time.Second})
+	exp := "\r\033[KScanned 50/200 probes (25%), 10/s, ETA 15s, 3 open"
+	if diff := cmp.Diff(exp, out.String()); diff != "" {
+		t.Errorf("Line mismatch (-want +got):\n%s", diff)
+	}
+
+	// Updates right after a redraw are skipped, except the last one
+	out.Reset()
+	l.update(scan.Progress{Done: 51, Total: 200, Elapsed: 5 * time.Second})
+	if out.Len() != 0 {
+		t.Errorf("Expect the update to be throttled, got %q", out.String())
+	}
+	l.update(scan.Progress{Done: 200, Total: 200, Open: 4, Elapsed: 20 * time.Second})
+	if exp := "\r\033[KScanned 200/200 probes (100%), 10/s, 4 open"; out.String() != exp {
+		t.Errorf("Expect %q, got %q", exp, out.String())
+	}
+
+	out.Reset()
+	l.clear()
+	l.clear()
+	if out.String() != "\r\033[K" {
+		t.Errorf("Expect the line erased once, got %q", out.String())
+	}
+}
+
+func TestScanSelection(t *testing.T) {
+	hl := &scan.HostList{}
+	for _, host := range []string{"localhost", "127.0.0.1"} {
+		if err := hl.Add(host); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := hl.SetInfo("localhost", scan.HostInfo{Group: "web", Tags: []string{"prod"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := hl.SetInfo("127.0.0.1", scan.HostInfo{Group: "db", Tags: []string{"prod", "legacy"}}); err != nil {
+		t.Fatal(err)
+	}
+	hostsFile := filepath.Join(t.TempDir(), "pScan.hosts")
+	if err := hl.Save(hostsFile); err != nil {
+		t.Fatal(err)
+	}
+
+	ln, err := net.Listen("tcp", net.JoinHostPort("localhost", "0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	port := ln.Addr().(*net.TCPAddr).Port
+
+	testCases := []struct {
+		name   string
+		query  string
+		groups []string
+		exp    []string
+		expErr error
+	}{
+		{name: "Tag", query: "prod AND NOT legacy", exp: []string{"localhost"}},
+		{name: "Group", groups: []string{"db"}, exp: []string{"127.0.0.1"}},
+		{name: "Groups", groups: []string{"db", "web"}, exp: []string{"127.0.0.1", "localhost"}},
+		{name: "TagAndGroup", query: "legacy", groups: []string{"web"}, expErr: errNoHostSelected},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := scanConfig{hostsFile: hostsFile, ports: []int{port}, groups: tc.groups}
+			if tc.query != "" {
+				if cfg.tags, err = scan.ParseTagQuery(tc.query); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var out bytes.Buffer
+			err := scanAction(context.Background(), &out, cfg)
+			if tc.expErr != nil {
+				if !errors.Is(err, tc.expErr) {
+					t.Errorf("Expect error %q, got %v", tc.expErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expect no error, got: %v\n", err)
+			}
+
+			var exp string
+			for _, host := range tc.exp {
+				exp += fmt.Sprintf("%s:\n\t%d: open\n\n", host, port)
+			}
+			if diff := cmp.Diff(exp, withoutLatency(out.String())); diff != "" {
+				t.Errorf("%s mismatch (-want +got):\n%s", t.Name(), diff)
+			}
+		})
+	}
+}
+
+func TestScanActionCanceled(t *testing.T) {
+	tmpFile := setUpFile(t, true, []string{"localhost"})
+	ports := []int{22, 80}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var out bytes.Buffer
+	err := scanAction(ctx, &out, scanConfig{hostsFile: tmpFile, ports: ports})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expect error %q, got %q instead", context.Canceled, err)
+	}
+
+	var expectPrintOut string
+	expectPrintOut += fmt.Sprintln("localhost:")
+	expectPrintOut += fmt.Sprintln("\t22: not scanned")
+	expectPrintOut += fmt.Sprintln("\t80: not scanned")
+	expectPrintOut += fmt.Sprintln()
+
 	got := out.String()
 	if diff := cmp.Diff(expectPrintOut, got); diff != "" {
 		t.Errorf("%s mismatch (-want +got):\n%s", t.Name(), diff)
 	}
 }
 
+func TestScanActionSetupError(t *testing.T) {
+	hostsFile := setUpFile(t, true, []string{"localhost", "10.0.0.0/8"})
+	store := history.Store{Dir: t.TempDir()}
+
+	var out bytes.Buffer
+	err := scanAction(context.Background(), &out, scanConfig{hostsFile: hostsFile, ports: []int{22}, history: &store})
+	if !errors.Is(err, scan.ErrTargetTooLarge) {
+		t.Errorf("Expect error %q, got %v", scan.ErrTargetTooLarge, err)
+	}
+	if strings.Contains(err.Error(), "partial results") {
+		t.Errorf("Expect no mention of partial results, got %q", err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("Expect nothing printed, got %q", out.String())
+	}
+
+	entries, err := store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("Expect no stored run, got %d", len(entries))
+	}
+}
+
+func TestScanHistory(t *testing.T) {
+	hostsFile := setUpFile(t, true, []string{"localhost"})
+	store := history.Store{Dir: t.TempDir()}
+
+	ln, err := net.Listen("tcp", net.JoinHostPort("localhost", "0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	port := ln.Addr().(*net.TCPAddr).Port
+
+	var errOut bytes.Buffer
+	cfg := scanConfig{hostsFile: hostsFile, ports: []int{port}, history: &store, errOut: &errOut}
+	if err := scanAction(context.Background(), io.Discard, cfg); err != nil {
+		t.Fatalf("Expect no error, got: %v\n", err)
+	}
+	ln.Close()
+	if err := scanAction(context.Background(), io.Discard, cfg); err != nil {
+		t.Fatalf("Expect no error, got: %v\n", err)
+	}
+
+	entries, err := store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Expect 2 stored runs, got %d", len(entries))
+	}
+	expSaved := fmt.Sprintf("Saved run: %s\nSaved run: %s\n", entries[0].ID, entries[1].ID)
+	if errOut.String() != expSaved {
+		t.Errorf("Expect %q, got %q", expSaved, errOut.String())
+	}
+
+	var out bytes.Buffer
+	if err := diffAction(&out, store, entries[0].ID, entries[1].ID); err != nil {
+		t.Fatalf("Expect no error, got: %v\n", err)
+	}
+	exp := fmt.Sprintf("- localhost:%d: closed (open -> closed)\n", port)
+	if diff := cmp.Diff(exp, out.String()); diff != "" {
+		t.Errorf("%s mismatch (-want +got):\n%s", t.Name(), diff)
+	}
+
+	out.Reset()
+	if err := historyShowAction(&out, store, entries[1].ID, report.Text{}); err != nil {
+		t.Fatalf("Expect no error, got: %v\n", err)
+	}
+	exp = fmt.Sprintf("localhost:\n\t%d: closed\n\n", port)
+	if diff := cmp.Diff(exp, withoutLatency(out.String())); diff != "" {
+		t.Errorf("%s mismatch (-want +got):\n%s", t.Name(), diff)
+	}
+}
+
+func TestScanPolicy(t *testing.T) {
+	hostsFile := setUpFile(t, true, []string{"localhost"})
+
+	ln, err := net.Listen("tcp", net.JoinHostPort("localhost", "0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	port := ln.Addr().(*net.TCPAddr).Port
+
+	testCases := []struct {
+		name          string
+		policy        string
+		expViolations int
+	}{
+		{"Met", fmt.Sprintf("hosts:\n  localhost:\n    open: [%d]\n", port), 0},
+		{"Violated", fmt.Sprintf("hosts:\n  localhost:\n    closed: [%d]\n", port), 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := policy.Parse([]byte(tc.policy))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			cfg := scanConfig{hostsFile: hostsFile, ports: []int{port}, policy: p}
+			err = scanAction(context.Background(), io.Discard, cfg)
+			if tc.expViolations == 0 {
+				if err != nil {
+					t.Errorf("Expect no error, got %q", err)
+				}
+				return
+			}
+
+			var policyErr *policy.Error
+			if !errors.As(err, &policyErr) {
+				t.Fatalf("Expect policy error, got %v", err)
+			}
+			if len(policyErr.Violations) != tc.expViolations {
+				t.Errorf("Expect %d violations, got %v", tc.expViolations, policyErr.Violations)
+			}
+		})
+	}
+}
+
+func TestScanPolicySelection(t *testing.T) {
+	hl := &scan.HostList{}
+	for _, host := range []string{"localhost", "db1"} {
+		if err := hl.Add(host); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := hl.SetInfo("localhost", scan.HostInfo{Tags: []string{"prod"}}); err != nil {
+		t.Fatal(err)
+	}
+	hostsFile := filepath.Join(t.TempDir(), "pScan.hosts")
+	if err := hl.Save(hostsFile); err != nil {
+		t.Fatal(err)
+	}
+
+	ln, err := net.Listen("tcp", net.JoinHostPort("localhost", "0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	port := ln.Addr().(*net.TCPAddr).Port
+
+	// db1 is in the host list but not selected, db2 is not in the host list at all
+	p, err := policy.Parse([]byte(fmt.Sprintf("hosts:\n  localhost:\n    open: [%d]\n  db1:\n    open: [5432]\n  db2:\n    open: [5432]\n", port)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tags, err := scan.ParseTagQuery("prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := scanConfig{hostsFile: hostsFile, ports: []int{port}, tags: tags, policy: p}
+	err = scanAction(context.Background(), io.Discard, cfg)
+	var policyErr *policy.Error
+	if !errors.As(err, &policyErr) {
+		t.Fatalf("Expect policy error, got %v", err)
+	}
+	exp := []policy.Violation{{Host: "db2", Expected: "host to be scanned", Got: "not in the host list"}}
+	if diff := cmp.Diff(exp, policyErr.Violations); diff != "" {
+		t.Errorf("%s mismatch (-want +got):\n%s", t.Name(), diff)
+	}
+}
+
 func TestIntegration(t *testing.T) {
 	hosts := []string{"host1", "host2", "host3"}
 	hostsFile := setUpFile(t, false, hosts)
@@ -161,7 +594,7 @@ func TestIntegration(t *testing.T) {
 		t.Fatalf("Expect no error, got: %v\n", err)
 	}
 
-	if err := scanAction(&out, hostsFile, nil); err != nil {
+	if err := scanAction(context.Background(), &out, scanConfig{hostsFile: hostsFile}); err != nil {
 		t.Fatalf("Expect no error, got: %v\n", err)
 	}
 
@@ -22,9 +22,11 @@ THE SOFTWARE.
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"slices"
 
 	"github.com/nguyenanhhao221/pScan/scan"
 	"github.com/spf13/cobra"
@@ -33,32 +35,120 @@ import (
 
 // addCmd represents the add command
 var addCmd = &cobra.Command{
-	Use:          "add <host1>...<hostn>",
-	Short:        "Add new host(s) to the host's list",
+	Use:   "add <host1>...<hostn>",
+	Short: "Add new host(s) to the host's list",
+	Long: `Add new host(s) to the host's list
+
+Hosts already in the list can be given attributes the same way, --tag adds
+to their tags and the other flags replace the attribute they set.`,
 	Aliases:      []string{"a"},
 	Args:         cobra.MinimumNArgs(1),
 	SilenceUsage: true,
 
 	RunE: func(cmd *cobra.Command, args []string) error {
 		hostsFile := viper.GetString("hosts-file")
-		return addAction(os.Stdout, hostsFile, args)
+		ports, err := cmd.Flags().GetString("ports")
+		if err != nil {
+			return err
+		}
+		group, err := cmd.Flags().GetString("group")
+		if err != nil {
+			return err
+		}
+		tags, err := cmd.Flags().GetStringSlice("tag")
+		if err != nil {
+			return err
+		}
+		owner, err := cmd.Flags().GetString("owner")
+		if err != nil {
+			return err
+		}
+		notes, err := cmd.Flags().GetString("notes")
+		if err != nil {
+			return err
+		}
+		maxExpand, err := cmd.Flags().GetInt("max-expand")
+		if err != nil {
+			return err
+		}
+		if ports != "" {
+			if _, err := scan.ParsePorts(ports); err != nil {
+				return err
+			}
+		}
+
+		info := scan.HostInfo{Ports: ports, Group: group, Tags: tags, Owner: owner, Notes: notes}
+		return addInfoAction(os.Stdout, hostsFile, args, info, maxExpand)
 	},
 }
 
 func init() {
 	hostsCmd.AddCommand(addCmd)
+	addCmd.Flags().StringP("ports", "p", "", "ports to scan on these hosts instead of the ports of the scan")
+	addCmd.Flags().String("group", "", "group the hosts belong to, used to select them with scan --group")
+	addCmd.Flags().StringSlice("tag", nil, "tag the hosts, can be repeated, used to select them with scan --tag")
+	addCmd.Flags().String("owner", "", "owner of the hosts")
+	addCmd.Flags().String("notes", "", "free text notes about the hosts")
+	addCmd.Flags().Int("max-expand", scan.DefaultMaxExpand, "maximum number of addresses a CIDR block or address range may expand to")
 }
 
 func addAction(out io.Writer, hostsFile string, args []string) error {
+	return addInfoAction(out, hostsFile, args, scan.HostInfo{}, scan.DefaultMaxExpand)
+}
+
+// addInfoAction adds the hosts to the hosts file along with their attributes.
+// Hosts already in the file get the attributes merged into theirs instead.
+// CIDR blocks and address ranges must not expand to more than maxExpand addresses
+func addInfoAction(out io.Writer, hostsFile string, args []string, info scan.HostInfo, maxExpand int) error {
+	for _, host := range args {
+		if _, err := scan.ExpandTarget(host, maxExpand); err != nil {
+			return err
+		}
+	}
+
 	hl := &scan.HostList{}
 	if err := hl.Load(hostsFile); err != nil {
 		return err
 	}
 	for _, host := range args {
-		if err := hl.Add(host); err != nil {
+		err := hl.Add(host)
+		switch {
+		case errors.Is(err, scan.ErrExists) && !info.IsZero():
+			if err := hl.SetInfo(host, mergeInfo(hl.Info[host], info)); err != nil {
+				return err
+			}
+			fmt.Fprintln(out, "Updated host:", host)
+			continue
+		case err != nil:
+			return err
+		}
+		if err := hl.SetInfo(host, info); err != nil {
 			return err
 		}
 		fmt.Fprintln(out, "Added host:", host)
 	}
 	return hl.Save(hostsFile)
 }
+
+// mergeInfo adds the tags of update to those of info and replaces the other attributes update sets
+func mergeInfo(info, update scan.HostInfo) scan.HostInfo {
+	if update.Ports != "" {
+		info.Ports = update.Ports
+	}
+	if update.Group != "" {
+		info.Group = update.Group
+	}
+	if update.Owner != "" {
+		info.Owner = update.Owner
+	}
+	if update.Notes != "" {
+		info.Notes = update.Notes
+	}
+	info.Tags = slices.Clone(info.Tags)
+	for _, tag := range update.Tags {
+		if !slices.Contains(info.Tags, tag) {
+			info.Tags = append(info.Tags, tag)
+		}
+	}
+	return info
+}
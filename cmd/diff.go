@@ -0,0 +1,112 @@
+/*
+Copyright © 2024 Hao Nguyen <hao@haonguyen.tech>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+
+	"github.com/nguyenanhhao221/pScan/history"
+	"github.com/nguyenanhhao221/pScan/scan"
+	"github.com/spf13/cobra"
+)
+
+// diffCmd represents the diff command
+var diffCmd = &cobra.Command{
+	Use:   "diff <runA> <runB>",
+	Short: "Compare two stored scan runs",
+	Long: `Compare two scan runs from the history
+
+Reports the hosts that appeared or disappeared and the ports that
+opened or closed between runA and the later runB.
+Use "pScan history list" to find the run IDs.`,
+	Args:         cobra.ExactArgs(2),
+	SilenceUsage: true,
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := historyStore()
+		if err != nil {
+			return err
+		}
+		return diffAction(os.Stdout, store, args[0], args[1])
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(diffCmd)
+}
+
+func diffAction(out io.Writer, store history.Store, idA, idB string) error {
+	a, err := store.Load(idA)
+	if err != nil {
+		return err
+	}
+	b, err := store.Load(idB)
+	if err != nil {
+		return err
+	}
+
+	changes := history.Diff(a, b)
+	if len(changes) == 0 {
+		_, err := fmt.Fprintln(out, "No changes")
+		return err
+	}
+
+	var output string
+	for _, c := range changes {
+		switch c.Kind {
+		case history.HostAppeared:
+			output += fmt.Sprintf("+ %s: host appeared\n", changedHost(c))
+		case history.HostDisappeared:
+			output += fmt.Sprintf("- %s: host disappeared\n", changedHost(c))
+		case history.PortOpened:
+			output += fmt.Sprintf("+ %s: opened (%s -> %s)\n", changedPort(c), c.From, c.To)
+		case history.PortClosed:
+			output += fmt.Sprintf("- %s: closed (%s -> %s)\n", changedPort(c), c.From, c.To)
+		}
+	}
+	_, err = fmt.Fprint(out, output)
+	return err
+}
+
+// changedHost names the host of a change along with its address when it has several
+func changedHost(c history.Change) string {
+	if c.Addr != "" {
+		return fmt.Sprintf("%s (%s)", c.Host, c.Addr)
+	}
+	return c.Host
+}
+
+// changedPort names the port of a change, UDP ports get a suffix
+func changedPort(c history.Change) string {
+	name := net.JoinHostPort(c.Host, strconv.Itoa(c.Port))
+	if c.Protocol == scan.ProtocolUDP {
+		name += "/udp"
+	}
+	if c.Addr != "" {
+		name += fmt.Sprintf(" (%s)", c.Addr)
+	}
+	return name
+}
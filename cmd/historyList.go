@@ -0,0 +1,79 @@
+/*
+Copyright © 2024 Hao Nguyen <hao@haonguyen.tech>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/nguyenanhhao221/pScan/history"
+	"github.com/nguyenanhhao221/pScan/scan"
+	"github.com/spf13/cobra"
+)
+
+// historyListCmd represents the history list command
+var historyListCmd = &cobra.Command{
+	Use:          "list",
+	Short:        "List stored scan runs, oldest first",
+	Aliases:      []string{"l"},
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := historyStore()
+		if err != nil {
+			return err
+		}
+		return historyListAction(os.Stdout, store)
+	},
+}
+
+func init() {
+	historyCmd.AddCommand(historyListCmd)
+}
+
+func historyListAction(out io.Writer, store history.Store) error {
+	entries, err := store.List()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSTARTED\tDURATION\tHOSTS\tPORTS\tOPEN")
+	for _, e := range entries {
+		r := e.Report
+		open := 0
+		for _, res := range r.Results {
+			for _, p := range res.PortStates {
+				if !p.Canceled && p.State == scan.StateOpen {
+					open++
+				}
+			}
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%d\n",
+			e.ID, r.Start.Local().Format("2006-01-02 15:04:05"), r.End.Sub(r.Start).Round(time.Millisecond), len(r.Results), len(r.Ports), open)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,70 @@
+/*
+Copyright © 2024 Hao Nguyen <hao@haonguyen.tech>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"io"
+	"os"
+	"strings"
+
+	"github.com/nguyenanhhao221/pScan/history"
+	"github.com/nguyenanhhao221/pScan/report"
+	"github.com/spf13/cobra"
+)
+
+// historyShowCmd represents the history show command
+var historyShowCmd = &cobra.Command{
+	Use:          "show <run>",
+	Short:        "Print a stored scan run",
+	Aliases:      []string{"s"},
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		output, err := cmd.Flags().GetString("output")
+		if err != nil {
+			return err
+		}
+		formatter, err := report.Get(output)
+		if err != nil {
+			return err
+		}
+		store, err := historyStore()
+		if err != nil {
+			return err
+		}
+		return historyShowAction(os.Stdout, store, args[0], formatter)
+	},
+}
+
+func init() {
+	historyCmd.AddCommand(historyShowCmd)
+	historyShowCmd.Flags().StringP("output", "o", "text", "output format, one of "+strings.Join(report.Names(), ", "))
+}
+
+func historyShowAction(out io.Writer, store history.Store, id string, formatter report.Formatter) error {
+	r, err := store.Load(id)
+	if err != nil {
+		return err
+	}
+	return formatter.Format(out, r)
+}
@@ -39,12 +39,25 @@ var listCmd = &cobra.Command{
 
 	RunE: func(cmd *cobra.Command, args []string) error {
 		hostsFile := viper.GetString("hosts-file")
+		expand, err := cmd.Flags().GetBool("expand")
+		if err != nil {
+			return err
+		}
+		if expand {
+			maxExpand, err := cmd.Flags().GetInt("max-expand")
+			if err != nil {
+				return err
+			}
+			return listExpandAction(os.Stdout, hostsFile, maxExpand)
+		}
 		return listAction(os.Stdout, hostsFile, args)
 	},
 }
 
 func init() {
 	hostsCmd.AddCommand(listCmd)
+	listCmd.Flags().BoolP("expand", "e", false, "show the addresses CIDR blocks and address ranges expand to")
+	listCmd.Flags().Int("max-expand", scan.DefaultMaxExpand, "maximum number of addresses a CIDR block or address range may expand to")
 }
 
 func listAction(out io.Writer, hostsFile string, args []string) error {
@@ -55,6 +68,10 @@ func listAction(out io.Writer, hostsFile string, args []string) error {
 
 	var output string
 	for _, host := range hl.Hosts {
+		if info := hl.Info[host]; !info.IsZero() {
+			output += fmt.Sprintln(host, info)
+			continue
+		}
 		output += fmt.Sprintln(host)
 	}
 	if _, err := fmt.Fprintf(out, "%s", output); err != nil {
@@ -62,3 +79,29 @@ func listAction(out io.Writer, hostsFile string, args []string) error {
 	}
 	return nil
 }
+
+func listExpandAction(out io.Writer, hostsFile string, maxExpand int) error {
+	hl := &scan.HostList{}
+	if err := hl.Load(hostsFile); err != nil {
+		return err
+	}
+
+	var output string
+	for _, host := range hl.Hosts {
+		addrs, err := scan.ExpandTarget(host, maxExpand)
+		if err != nil {
+			return err
+		}
+		if len(addrs) == 1 && addrs[0] == host {
+			output += fmt.Sprintln(host)
+			continue
+		}
+
+		output += fmt.Sprintf("%s:\n", host)
+		for _, a := range addrs {
+			output += fmt.Sprintf("\t%s\n", a)
+		}
+	}
+	_, err := fmt.Fprint(out, output)
+	return err
+}
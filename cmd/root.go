@@ -22,10 +22,14 @@ THE SOFTWARE.
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 
+	"github.com/nguyenanhhao221/pScan/history"
+	"github.com/nguyenanhhao221/pScan/policy"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -49,8 +53,13 @@ to quickly create a Cobra application.`,
 
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
+// It exits with status 2 when a scan violates its policy so CI can tell it apart from other errors.
 func Execute() {
 	err := rootCmd.Execute()
+	var policyErr *policy.Error
+	if errors.As(err, &policyErr) {
+		os.Exit(2)
+	}
 	if err != nil {
 		os.Exit(1)
 	}
@@ -65,6 +74,7 @@ func init() {
 
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.pScan.yaml)")
 	rootCmd.PersistentFlags().StringP("hosts-file", "f", "pScan.hosts", "pScan hosts file")
+	rootCmd.PersistentFlags().String("history-dir", "", "directory where scan runs are stored (default is $HOME/.pScan/history)")
 	replacer := strings.NewReplacer("-", "_")
 	viper.SetEnvKeyReplacer(replacer)
 	viper.SetEnvPrefix("PSCAN")
@@ -74,6 +84,10 @@ func init() {
 		fmt.Fprintf(os.Stderr, "Fail to bind flag of Viper config: %s\n", err.Error())
 		os.Exit(1)
 	}
+	if err := viper.BindPFlag("history-dir", rootCmd.PersistentFlags().Lookup("history-dir")); err != nil {
+		fmt.Fprintf(os.Stderr, "Fail to bind flag of Viper config: %s\n", err.Error())
+		os.Exit(1)
+	}
 	versionTemplate := `{{printf "%s: %s - version %s\n" .Name .Short .Version}}`
 	rootCmd.SetVersionTemplate(versionTemplate)
 }
@@ -101,3 +115,16 @@ func initConfig() {
 		fmt.Fprintln(os.Stderr, "Using config file:", viper.ConfigFileUsed())
 	}
 }
+
+// historyStore returns the store holding past scan runs, from the "history-dir"
+// config value or under the home directory when it is not set.
+func historyStore() (history.Store, error) {
+	if dir := viper.GetString("history-dir"); dir != "" {
+		return history.Store{Dir: dir}, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return history.Store{}, err
+	}
+	return history.Store{Dir: filepath.Join(home, ".pScan", "history")}, nil
+}
@@ -0,0 +1,180 @@
+package history
+
+import (
+	"github.com/nguyenanhhao221/pScan/report"
+	"github.com/nguyenanhhao221/pScan/scan"
+)
+
+// ChangeKind is the kind of difference found between two runs
+type ChangeKind int
+
+const (
+	HostAppeared ChangeKind = iota
+	HostDisappeared
+	PortOpened
+	PortClosed
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case HostAppeared:
+		return "appeared"
+	case HostDisappeared:
+		return "disappeared"
+	case PortOpened:
+		return "opened"
+	case PortClosed:
+		return "closed"
+	}
+	return "unknown"
+}
+
+// Change is a single difference between two runs.
+// Port, Protocol, From and To are only set for PortOpened and PortClosed.
+// Addr is only set when the host resolved to several addresses, or when
+// an address of the host appeared or disappeared while the host stayed
+type Change struct {
+	Kind     ChangeKind
+	Host     string
+	Addr     string
+	Port     int
+	Protocol string
+	From     scan.State
+	To       scan.State
+}
+
+// Diff compares run a with the later run b. Hosts appear or disappear when
+// they are found in only one of the runs, ports open or close when they are
+// open in only one of them. Ports that were not probed in either run are ignored.
+// Hosts are compared address by address, a run without addresses compares by host alone.
+// Changes are listed in the order of the hosts and ports of b, then of a
+func Diff(a, b report.Report) []Change {
+	before := foundHosts(a)
+	after := foundHosts(b)
+
+	var changes []Change
+	for _, key := range after.order {
+		if _, ok := before.find(key); !ok {
+			changes = append(changes, hostChange(HostAppeared, after.results[key], before))
+		}
+	}
+	for _, key := range before.order {
+		if _, ok := after.find(key); !ok {
+			changes = append(changes, hostChange(HostDisappeared, before.results[key], after))
+		}
+	}
+
+	for _, key := range after.order {
+		old, ok := before.find(key)
+		if !ok {
+			continue
+		}
+		oldPorts := map[portKey]scan.PortState{}
+		for _, p := range old.PortStates {
+			if !p.Canceled {
+				oldPorts[keyOf(p)] = p
+			}
+		}
+
+		res := after.results[key]
+		for _, p := range res.PortStates {
+			key := keyOf(p)
+			prev, ok := oldPorts[key]
+			if p.Canceled || !ok {
+				continue
+			}
+			var kind ChangeKind
+			switch {
+			case p.State == scan.StateOpen && prev.State != scan.StateOpen:
+				kind = PortOpened
+			case p.State != scan.StateOpen && prev.State == scan.StateOpen:
+				kind = PortClosed
+			default:
+				continue
+			}
+			c := changeOf(kind, res)
+			c.Port, c.Protocol, c.From, c.To = p.Port, key.protocol, prev.State, p.State
+			changes = append(changes, c)
+		}
+	}
+	return changes
+}
+
+// hostChange returns a change of kind to the host of res, naming its address
+// when the host is still in the other run under another address
+func hostChange(kind ChangeKind, res scan.Results, other hostIndex) Change {
+	c := changeOf(kind, res)
+	if _, ok := other.hosts[res.Host]; ok {
+		c.Addr = res.Addr
+	}
+	return c
+}
+
+// changeOf returns a change of kind to the host of res
+func changeOf(kind ChangeKind, res scan.Results) Change {
+	c := Change{Kind: kind, Host: res.Host}
+	if res.Name() != res.Host {
+		c.Addr = res.Addr
+	}
+	return c
+}
+
+// portKey identifies a port across runs, the same port number over TCP and UDP are different ports
+type portKey struct {
+	protocol string
+	port     int
+}
+
+// keyOf returns the key of a port, runs saved before UDP scanning existed only scanned TCP
+func keyOf(p scan.PortState) portKey {
+	if p.Protocol == "" {
+		return portKey{protocol: scan.ProtocolTCP, port: p.Port}
+	}
+	return portKey{protocol: p.Protocol, port: p.Port}
+}
+
+// hostKey identifies a host across runs by its name and the address it was scanned on
+type hostKey struct {
+	host string
+	addr string
+}
+
+type hostIndex struct {
+	order   []hostKey
+	results map[hostKey]scan.Results
+	// hosts holds the first key of each host name
+	hosts map[string]hostKey
+}
+
+// find returns the results of key. Runs saved before addresses were recorded, and
+// hosts left for a proxy to look up, have no address and match a host by name alone
+func (idx hostIndex) find(key hostKey) (scan.Results, bool) {
+	if res, ok := idx.results[key]; ok {
+		return res, true
+	}
+	if key.addr != "" {
+		res, ok := idx.results[hostKey{host: key.host}]
+		return res, ok
+	}
+	first, ok := idx.hosts[key.host]
+	return idx.results[first], ok
+}
+
+// foundHosts indexes the hosts of a run that were found, keeping their order
+func foundHosts(r report.Report) hostIndex {
+	idx := hostIndex{results: map[hostKey]scan.Results{}, hosts: map[string]hostKey{}}
+	for _, res := range r.Results {
+		if res.NotFound {
+			continue
+		}
+		key := hostKey{host: res.Host, addr: res.Addr}
+		if _, ok := idx.results[key]; !ok {
+			idx.order = append(idx.order, key)
+		}
+		if _, ok := idx.hosts[res.Host]; !ok {
+			idx.hosts[res.Host] = key
+		}
+		idx.results[key] = res
+	}
+	return idx
+}
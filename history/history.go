@@ -0,0 +1,112 @@
+// Package history keeps past scan runs on disk and compares them
+package history
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/nguyenanhhao221/pScan/report"
+)
+
+// idLayout is the time layout used to name runs, it sorts in chronological order
+const idLayout = "20060102-150405.000"
+
+const fileExt = ".json"
+
+var ErrNotFound = errors.New("run not found in history")
+
+// Store saves scan runs as JSON reports in a directory, one file per run
+type Store struct {
+	Dir string
+}
+
+// Entry summarises a run stored in the history
+type Entry struct {
+	ID     string
+	Report report.Report
+}
+
+// Save stores r in the history and returns the ID of the new run
+func (s Store) Save(r report.Report) (string, error) {
+	if err := os.MkdirAll(s.Dir, 0755); err != nil {
+		return "", err
+	}
+
+	id := r.Start.UTC().Format(idLayout)
+	// Runs started within the same millisecond get a numbered suffix
+	for n := 1; ; n++ {
+		f, err := os.OpenFile(s.path(id), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if errors.Is(err, os.ErrExist) {
+			id = fmt.Sprintf("%s-%d", r.Start.UTC().Format(idLayout), n)
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+
+		if err := (report.JSON{}).Format(f, r); err != nil {
+			f.Close()
+			return "", err
+		}
+		return id, f.Close()
+	}
+}
+
+// Load reads the run with the given ID
+func (s Store) Load(id string) (report.Report, error) {
+	if id == "" || strings.ContainsAny(id, `/\`) {
+		return report.Report{}, fmt.Errorf("%w: %q", ErrNotFound, id)
+	}
+
+	f, err := os.Open(s.path(id))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return report.Report{}, fmt.Errorf("%w: %q", ErrNotFound, id)
+		}
+		return report.Report{}, err
+	}
+	defer f.Close()
+
+	r, err := report.ReadJSON(f)
+	if err != nil {
+		return report.Report{}, fmt.Errorf("reading run %q: %w", id, err)
+	}
+	return r, nil
+}
+
+// List returns every run in the history, oldest first
+func (s Store) List() ([]Entry, error) {
+	files, err := os.ReadDir(s.Dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var entries []Entry
+	for _, f := range files {
+		id, ok := strings.CutSuffix(f.Name(), fileExt)
+		if f.IsDir() || !ok {
+			continue
+		}
+		r, err := s.Load(id)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, Entry{ID: id, Report: r})
+	}
+
+	slices.SortStableFunc(entries, func(a, b Entry) int {
+		return a.Report.Start.Compare(b.Report.Start)
+	})
+	return entries, nil
+}
+
+func (s Store) path(id string) string {
+	return filepath.Join(s.Dir, id+fileExt)
+}
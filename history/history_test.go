@@ -0,0 +1,202 @@
+package history_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nguyenanhhao221/pScan/history"
+	"github.com/nguyenanhhao221/pScan/report"
+	"github.com/nguyenanhhao221/pScan/scan"
+)
+
+func newReport(start time.Time, results ...scan.Results) report.Report {
+	return report.Report{Start: start, End: start.Add(time.Second), Ports: []int{22, 80, 443}, Results: results}
+}
+
+func host(name string, open ...int) scan.Results {
+	res := scan.Results{Host: name, Target: name}
+	for _, p := range []int{22, 80, 443} {
+		ps := scan.PortState{Port: p, Protocol: scan.ProtocolTCP, State: scan.StateClosed, Reason: "conn-refused"}
+		for _, o := range open {
+			if o == p {
+				ps.State, ps.Reason = scan.StateOpen, "syn-ack"
+			}
+		}
+		res.PortStates = append(res.PortStates, ps)
+	}
+	return res
+}
+
+func TestStore(t *testing.T) {
+	store := history.Store{Dir: t.TempDir()}
+
+	entries, err := store.List()
+	if err != nil {
+		t.Fatalf("Expect no error on empty history, got %q", err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("Expect empty history, got %d runs", len(entries))
+	}
+
+	start := time.Date(2024, 10, 1, 12, 0, 0, 0, time.UTC)
+	second := newReport(start.Add(time.Hour), host("host1", 22))
+	first := newReport(start, host("host1", 22, 443))
+
+	// Save out of order and twice at the same time to check ordering and unique IDs
+	var ids []string
+	for _, r := range []report.Report{second, first, first} {
+		id, err := store.Save(r)
+		if err != nil {
+			t.Fatalf("Expect no error saving, got %q", err)
+		}
+		ids = append(ids, id)
+	}
+	if ids[1] == ids[2] {
+		t.Errorf("Expect unique IDs for runs started at the same time, got %q twice", ids[1])
+	}
+
+	entries, err = store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("Expect 3 runs, got %d", len(entries))
+	}
+	if entries[2].ID != ids[0] {
+		t.Errorf("Expect latest run %q last, got %q", ids[0], entries[2].ID)
+	}
+
+	got, err := store.Load(ids[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(second, got); diff != "" {
+		t.Errorf("%s mismatch (-want +got):\n%s", t.Name(), diff)
+	}
+
+	for _, id := range []string{"nope", "", "../" + ids[0]} {
+		if _, err := store.Load(id); !errors.Is(err, history.ErrNotFound) {
+			t.Errorf("Expect error %q loading %q, got %v", history.ErrNotFound, id, err)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	start := time.Date(2024, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	gone := host("gone", 22)
+	unresolved := scan.Results{Host: "web2", Target: "web2", NotFound: true}
+	canceled := host("web1", 22)
+	canceled.PortStates[1].Canceled = true
+	filtered := host("web1", 22, 80)
+	filtered.PortStates[2].State = scan.StateFiltered
+	udp := host("web1")
+	for i := range udp.PortStates {
+		udp.PortStates[i].Protocol = scan.ProtocolUDP
+	}
+	dual := []string{"10.0.0.1", "2001:db8::1"}
+	v4, v6 := host("web1", 22), host("web1", 22, 443)
+	v4.Addrs, v4.Addr = dual, dual[0]
+	v6.Addrs, v6.Addr = dual, dual[1]
+	v6Closed := host("web1", 22)
+	v6Closed.Addrs, v6Closed.Addr = dual, dual[1]
+	v4Only := host("web1", 22)
+	v4Only.Addrs, v4Only.Addr = dual[:1], dual[0]
+	moved := host("web1", 22)
+	moved.Addrs, moved.Addr = []string{"10.0.0.2"}, "10.0.0.2"
+
+	testCases := []struct {
+		name string
+		a, b report.Report
+		exp  []history.Change
+	}{
+		{
+			name: "NoChanges",
+			a:    newReport(start, host("web1", 22)),
+			b:    newReport(start, host("web1", 22)),
+		},
+		{
+			name: "Ports",
+			a:    newReport(start, host("web1", 22, 443)),
+			b:    newReport(start, host("web1", 80, 443)),
+			exp: []history.Change{
+				{Kind: history.PortClosed, Host: "web1", Port: 22, Protocol: scan.ProtocolTCP, From: scan.StateOpen, To: scan.StateClosed},
+				{Kind: history.PortOpened, Host: "web1", Port: 80, Protocol: scan.ProtocolTCP, From: scan.StateClosed, To: scan.StateOpen},
+			},
+		},
+		{
+			name: "Hosts",
+			a:    newReport(start, host("web1"), gone, host("web2")),
+			b:    newReport(start, host("web1"), host("new", 443), unresolved),
+			exp: []history.Change{
+				{Kind: history.HostAppeared, Host: "new"},
+				{Kind: history.HostDisappeared, Host: "gone"},
+				{Kind: history.HostDisappeared, Host: "web2"},
+			},
+		},
+		{
+			name: "CanceledIgnored",
+			a:    newReport(start, host("web1", 80)),
+			b:    newReport(start, canceled),
+			exp: []history.Change{
+				{Kind: history.PortOpened, Host: "web1", Port: 22, Protocol: scan.ProtocolTCP, From: scan.StateClosed, To: scan.StateOpen},
+			},
+		},
+		{
+			name: "OnlyOpenTransitions",
+			a:    newReport(start, host("web1", 22, 80)),
+			b:    newReport(start, filtered),
+		},
+		{
+			name: "ProtocolsApart",
+			a:    newReport(start, host("web1", 22, 80)),
+			b:    newReport(start, udp),
+		},
+		{
+			name: "AddressesApart",
+			a:    newReport(start, v4, v6),
+			b:    newReport(start, v4, v6Closed),
+			exp: []history.Change{
+				{Kind: history.PortClosed, Host: "web1", Addr: "2001:db8::1", Port: 443, Protocol: scan.ProtocolTCP, From: scan.StateOpen, To: scan.StateClosed},
+			},
+		},
+		{
+			// The address the host already had is still compared with itself
+			name: "AddressAdded",
+			a:    newReport(start, v4Only),
+			b:    newReport(start, v4, v6),
+			exp: []history.Change{
+				{Kind: history.HostAppeared, Host: "web1", Addr: "2001:db8::1"},
+			},
+		},
+		{
+			name: "AddressChanged",
+			a:    newReport(start, v4Only),
+			b:    newReport(start, moved),
+			exp: []history.Change{
+				{Kind: history.HostAppeared, Host: "web1", Addr: "10.0.0.2"},
+				{Kind: history.HostDisappeared, Host: "web1", Addr: "10.0.0.1"},
+			},
+		},
+		{
+			// Runs saved before addresses were recorded compare every address with the host
+			name: "NoAddress",
+			a:    newReport(start, host("web1", 22)),
+			b:    newReport(start, v4, v6),
+			exp: []history.Change{
+				{Kind: history.PortOpened, Host: "web1", Addr: "2001:db8::1", Port: 443, Protocol: scan.ProtocolTCP, From: scan.StateClosed, To: scan.StateOpen},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := history.Diff(tc.a, tc.b)
+			if diff := cmp.Diff(tc.exp, got); diff != "" {
+				t.Errorf("%s mismatch (-want +got):\n%s", t.Name(), diff)
+			}
+		})
+	}
+}
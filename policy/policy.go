@@ -0,0 +1,242 @@
+// Package policy checks scan results against the port states expected for each host
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/nguyenanhhao221/pScan/scan"
+	"gopkg.in/yaml.v3"
+)
+
+var ErrInvalidPolicy = errors.New("invalid policy")
+
+// Policy is the expected state of the scanned hosts, usually loaded from a YAML file like:
+//
+//	default:
+//	  closed: [23]
+//	hosts:
+//	  web1:
+//	    open: [443]
+//	    closed: "22,3306"
+//
+// Ports are given as a list or as a port spec understood by scan.ParsePorts.
+// The default expectations apply to every scanned host, on top of its own.
+type Policy struct {
+	Default Expect            `yaml:"default"`
+	Hosts   map[string]Expect `yaml:"hosts"`
+}
+
+// Expect lists the expected state of the ports of a host.
+// Open ports must accept connections, Closed ports must not, whether they
+// are refused or filtered, and Filtered ports must not answer at all
+type Expect struct {
+	Open     Ports `yaml:"open"`
+	Closed   Ports `yaml:"closed"`
+	Filtered Ports `yaml:"filtered"`
+}
+
+// Ports is a list of ports that can be written in YAML as a list or as a port spec
+type Ports []int
+
+func (p *Ports) UnmarshalYAML(value *yaml.Node) error {
+	spec := value.Value
+	if value.Kind == yaml.SequenceNode {
+		var ports []int
+		if err := value.Decode(&ports); err != nil {
+			return err
+		}
+		items := make([]string, 0, len(ports))
+		for _, port := range ports {
+			items = append(items, strconv.Itoa(port))
+		}
+		spec = strings.Join(items, ",")
+	}
+
+	ports, err := scan.ParsePorts(spec)
+	if err != nil {
+		return fmt.Errorf("line %d: %w", value.Line, err)
+	}
+	*p = ports
+	return nil
+}
+
+// Load reads a policy from a YAML file
+func Load(policyFile string) (*Policy, error) {
+	b, err := os.ReadFile(policyFile)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(b)
+}
+
+// Parse reads a policy from YAML
+func Parse(b []byte) (*Policy, error) {
+	p := &Policy{}
+	if err := yaml.Unmarshal(b, p); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidPolicy, err)
+	}
+
+	if err := p.Default.validate(); err != nil {
+		return nil, fmt.Errorf("%w: default: %s", ErrInvalidPolicy, err)
+	}
+	for host, e := range p.Hosts {
+		if err := e.validate(); err != nil {
+			return nil, fmt.Errorf("%w: host %s: %s", ErrInvalidPolicy, host, err)
+		}
+	}
+	return p, nil
+}
+
+// validate makes sure no port is expected in two different states
+func (e Expect) validate() error {
+	seen := map[int]string{}
+	for _, s := range e.states() {
+		for _, port := range s.ports {
+			if prev, ok := seen[port]; ok {
+				return fmt.Errorf("port %d is expected both %s and %s", port, prev, s.name)
+			}
+			seen[port] = s.name
+		}
+	}
+	return nil
+}
+
+type expectedState struct {
+	name  string
+	ports Ports
+	match func(scan.State) bool
+}
+
+func (e Expect) states() []expectedState {
+	return []expectedState{
+		{"open", e.Open, func(s scan.State) bool { return s == scan.StateOpen }},
+		{"closed", e.Closed, func(s scan.State) bool { return s == scan.StateClosed || s == scan.StateFiltered }},
+		{"filtered", e.Filtered, func(s scan.State) bool { return s == scan.StateFiltered }},
+	}
+}
+
+// Violation is a difference between the policy and the scan results.
+// Port is zero when the violation is about the host itself. Addr is the
+// address that was probed, only set when the host has more than one
+type Violation struct {
+	Host     string
+	Addr     string
+	Port     int
+	Expected string
+	Got      string
+}
+
+func (v Violation) String() string {
+	where := v.Host
+	if v.Port != 0 {
+		where = net.JoinHostPort(v.Host, strconv.Itoa(v.Port))
+	}
+	if v.Addr != "" {
+		where += " (" + v.Addr + ")"
+	}
+	return fmt.Sprintf("%s: expected %s, got %s", where, v.Expected, v.Got)
+}
+
+// Error is returned when the scan results violate the policy
+type Error struct {
+	Violations []Violation
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("policy violated by %d port(s) or host(s):", len(e.Violations))
+	for _, v := range e.Violations {
+		msg += "\n  " + v.String()
+	}
+	return msg
+}
+
+// Check compares the results with the policy and returns every violation, in
+// the order of the results then of the hosts of the policy that were not scanned.
+// A host listed in the policy matches results by host name or by host list entry
+func (p *Policy) Check(results []scan.Results) []Violation {
+	var violations []Violation
+	checked := map[string]bool{}
+
+	for _, res := range results {
+		expects := []Expect{p.Default}
+		names := []string{res.Host}
+		if res.Target != "" && res.Target != res.Host {
+			names = append(names, res.Target)
+		}
+		for _, name := range names {
+			if e, ok := p.Hosts[name]; ok {
+				expects = append(expects, e)
+				checked[name] = true
+			}
+		}
+		if len(expects) == 1 && !p.Default.any() {
+			continue
+		}
+
+		if res.NotFound {
+			violations = append(violations, Violation{Host: res.Host, Expected: "host to be found", Got: "host not found"})
+			continue
+		}
+
+		// Each address of a dual-stack host is checked on its own
+		addr := ""
+		if len(res.Addrs) > 1 {
+			addr = res.Addr
+		}
+		ports := map[int]scan.PortState{}
+		for _, ps := range res.PortStates {
+			ports[ps.Port] = ps
+		}
+		for _, e := range expects {
+			for _, s := range e.states() {
+				for _, port := range s.ports {
+					ps, ok := ports[port]
+					switch {
+					case !ok || ps.Canceled:
+						violations = append(violations, Violation{Host: res.Host, Addr: addr, Port: port, Expected: s.name, Got: "not scanned"})
+					case !s.match(ps.State):
+						violations = append(violations, Violation{Host: res.Host, Addr: addr, Port: port, Expected: s.name, Got: ps.State.String()})
+					}
+				}
+			}
+		}
+	}
+
+	hosts := make([]string, 0, len(p.Hosts))
+	for host := range p.Hosts {
+		if !checked[host] {
+			hosts = append(hosts, host)
+		}
+	}
+	slices.Sort(hosts)
+	for _, host := range hosts {
+		violations = append(violations, Violation{Host: host, Expected: "host to be scanned", Got: "not in the host list"})
+	}
+	return violations
+}
+
+// Only returns a copy of the policy with the hosts for which keep returns true,
+// the default expectations are kept as they are
+func (p *Policy) Only(keep func(host string) bool) *Policy {
+	only := &Policy{Default: p.Default}
+	for host, e := range p.Hosts {
+		if !keep(host) {
+			continue
+		}
+		if only.Hosts == nil {
+			only.Hosts = map[string]Expect{}
+		}
+		only.Hosts[host] = e
+	}
+	return only
+}
+
+func (e Expect) any() bool {
+	return len(e.Open)+len(e.Closed)+len(e.Filtered) > 0
+}
@@ -0,0 +1,154 @@
+package policy_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nguyenanhhao221/pScan/policy"
+	"github.com/nguyenanhhao221/pScan/scan"
+)
+
+const testPolicy = `
+default:
+  closed: [23]
+hosts:
+  web1:
+    open: [443]
+    closed: "22,3306"
+  10.0.0.0/31:
+    filtered: [22]
+  db1:
+    open: [5432]
+`
+
+func TestLoad(t *testing.T) {
+	policyFile := filepath.Join(t.TempDir(), "policy.yaml")
+	if err := os.WriteFile(policyFile, []byte(testPolicy), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := policy.Load(policyFile)
+	if err != nil {
+		t.Fatalf("Expect no error, got %q", err)
+	}
+
+	exp := &policy.Policy{
+		Default: policy.Expect{Closed: policy.Ports{23}},
+		Hosts: map[string]policy.Expect{
+			"web1":        {Open: policy.Ports{443}, Closed: policy.Ports{22, 3306}},
+			"10.0.0.0/31": {Filtered: policy.Ports{22}},
+			"db1":         {Open: policy.Ports{5432}},
+		},
+	}
+	if diff := cmp.Diff(exp, p); diff != "" {
+		t.Errorf("%s mismatch (-want +got):\n%s", t.Name(), diff)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	testCases := []struct {
+		name   string
+		policy string
+	}{
+		{"BadYAML", "hosts: ["},
+		{"BadPort", "hosts:\n  web1:\n    open: [70000]\n"},
+		{"BadSpec", "hosts:\n  web1:\n    open: \"foo\"\n"},
+		{"Conflict", "hosts:\n  web1:\n    open: [22]\n    closed: \"20-25\"\n"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := policy.Parse([]byte(tc.policy))
+			if err == nil {
+				t.Fatal("Expect error, got 'nil'")
+			}
+		})
+	}
+
+	if _, err := policy.Parse([]byte("hosts: [")); !errors.Is(err, policy.ErrInvalidPolicy) {
+		t.Errorf("Expect error %q, got %q", policy.ErrInvalidPolicy, err)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	p, err := policy.Parse([]byte(testPolicy))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results := []scan.Results{
+		{Host: "web1", Target: "web1", Addrs: []string{"10.0.0.5"}, Addr: "10.0.0.5", PortStates: []scan.PortState{
+			{Port: 22, State: scan.StateFiltered},
+			{Port: 23, State: scan.StateOpen},
+			{Port: 443, State: scan.StateClosed},
+			{Port: 3306, Canceled: true},
+		}},
+		// Only the IPv6 address of this dual-stack host leaves port 23 open
+		{Host: "dual", Target: "dual", Addrs: []string{"10.0.0.9", "fd00::9"}, Addr: "10.0.0.9", PortStates: []scan.PortState{
+			{Port: 23, State: scan.StateClosed},
+		}},
+		{Host: "dual", Target: "dual", Addrs: []string{"10.0.0.9", "fd00::9"}, Addr: "fd00::9", PortStates: []scan.PortState{
+			{Port: 23, State: scan.StateOpen},
+		}},
+		{Host: "10.0.0.0", Target: "10.0.0.0/31", PortStates: []scan.PortState{
+			{Port: 22, State: scan.StateFiltered},
+			{Port: 23, State: scan.StateClosed},
+		}},
+		{Host: "10.0.0.1", Target: "10.0.0.0/31", PortStates: []scan.PortState{
+			{Port: 22, State: scan.StateClosed},
+			{Port: 23, State: scan.StateClosed},
+		}},
+		{Host: "other", Target: "other", NotFound: true},
+	}
+
+	exp := []policy.Violation{
+		{Host: "web1", Port: 23, Expected: "closed", Got: "open"},
+		{Host: "web1", Port: 443, Expected: "open", Got: "closed"},
+		{Host: "web1", Port: 3306, Expected: "closed", Got: "not scanned"},
+		{Host: "dual", Addr: "fd00::9", Port: 23, Expected: "closed", Got: "open"},
+		{Host: "10.0.0.1", Port: 22, Expected: "filtered", Got: "closed"},
+		{Host: "other", Expected: "host to be found", Got: "host not found"},
+		{Host: "db1", Expected: "host to be scanned", Got: "not in the host list"},
+	}
+
+	got := p.Check(results)
+	if diff := cmp.Diff(exp, got); diff != "" {
+		t.Errorf("%s mismatch (-want +got):\n%s", t.Name(), diff)
+	}
+
+	perr := &policy.Error{Violations: got[2:4]}
+	expMsg := "policy violated by 2 port(s) or host(s):\n  web1:3306: expected closed, got not scanned\n  dual:23 (fd00::9): expected closed, got open"
+	if perr.Error() != expMsg {
+		t.Errorf("Expect %q, got %q", expMsg, perr.Error())
+	}
+}
+
+func TestOnly(t *testing.T) {
+	p, err := policy.Parse([]byte(testPolicy))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results := []scan.Results{
+		{Host: "web1", Target: "web1", PortStates: []scan.PortState{
+			{Port: 22, State: scan.StateClosed},
+			{Port: 23, State: scan.StateClosed},
+			{Port: 443, State: scan.StateOpen},
+			{Port: 3306, State: scan.StateClosed},
+		}},
+	}
+
+	only := p.Only(func(host string) bool { return host == "web1" })
+	if got := only.Check(results); len(got) != 0 {
+		t.Errorf("Expect no violation, got %v", got)
+	}
+	if diff := cmp.Diff(p.Default, only.Default); diff != "" {
+		t.Errorf("Default mismatch (-want +got):\n%s", diff)
+	}
+	if len(p.Hosts) != 3 {
+		t.Errorf("Expect the policy left untouched, got %d hosts", len(p.Hosts))
+	}
+}
@@ -0,0 +1,91 @@
+package report
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nguyenanhhao221/pScan/scan"
+)
+
+var csvHeader = []string{"host", "target", "found", "wildcard", "addresses", "address", "port", "protocol", "state", "reason", "latency_ms", "canceled", "banner", "service", "version",
+	"tls_version", "tls_cipher", "tls_subject", "tls_sans", "tls_issuer", "tls_not_after", "tls_warnings",
+	"http_status", "http_server", "http_title", "http_redirects"}
+
+// CSV writes one row per scanned port, hosts that were not found get a
+// single row with empty port columns
+type CSV struct{}
+
+func (CSV) Format(out io.Writer, r Report) error {
+	w := csv.NewWriter(out)
+	if err := w.Write(csvHeader); err != nil {
+		return err
+	}
+
+	for _, res := range r.Results {
+		host := []string{res.Host, res.Target, strconv.FormatBool(!res.NotFound), strconv.FormatBool(res.Wildcard), strings.Join(res.Addrs, " "), res.Addr}
+		if len(res.PortStates) == 0 {
+			row := append(host, make([]string, len(csvHeader)-len(host))...)
+			if err := w.Write(row); err != nil {
+				return err
+			}
+			continue
+		}
+
+		for _, p := range res.PortStates {
+			row := append(host[:len(host):len(host)],
+				strconv.Itoa(p.Port),
+				portProtocol(p.Protocol),
+				p.State.String(),
+				p.Reason,
+				csvLatency(p.Latency),
+				strconv.FormatBool(p.Canceled),
+				p.Banner,
+				p.Service,
+				p.Version,
+			)
+			row = append(row, csvTLS(p.TLS)...)
+			row = append(row, csvHTTP(p.HTTP)...)
+			if err := w.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+
+	w.Flush()
+	return w.Error()
+}
+
+// csvLatency returns the latency of a port in milliseconds, empty when no answer came back
+func csvLatency(d time.Duration) string {
+	if d <= 0 {
+		return ""
+	}
+	return strconv.FormatFloat(milliseconds(d), 'f', 3, 64)
+}
+
+// csvTLS returns the TLS columns of a port, empty when the port did not speak TLS
+func csvTLS(t *scan.TLSInfo) []string {
+	if t == nil {
+		return make([]string, 7)
+	}
+	return []string{
+		t.Version,
+		t.CipherSuite,
+		t.Subject,
+		strings.Join(t.SANs, " "),
+		t.Issuer,
+		t.NotAfter.Format(time.RFC3339),
+		strings.Join(t.Warnings, "; "),
+	}
+}
+
+// csvHTTP returns the HTTP columns of a port, empty when the port was not probed over HTTP
+func csvHTTP(h *scan.HTTPInfo) []string {
+	if h == nil {
+		return make([]string, 4)
+	}
+	return []string{strconv.Itoa(h.StatusCode), h.Server, h.Title, strings.Join(h.Redirects, " ")}
+}
@@ -0,0 +1,294 @@
+package report
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/nguyenanhhao221/pScan/scan"
+	"gopkg.in/yaml.v3"
+)
+
+var ErrUnsupportedSchema = errors.New("unsupported report schema version")
+
+// SchemaVersion is the version of the document written by the JSON and YAML formats.
+// It changes whenever a field is renamed or removed, or its meaning changes, adding
+// fields keeps the version. Since version 2 a host is listed once per address it was
+// scanned on, so the same host can appear several times, told apart by its address
+const SchemaVersion = 2
+
+// document is the stable schema shared by the JSON and YAML formats.
+// It is kept apart from the scan types so the scan package can change
+// without breaking the consumers of the report
+type document struct {
+	SchemaVersion int            `json:"schema_version" yaml:"schema_version"`
+	Start         time.Time      `json:"start" yaml:"start"`
+	End           time.Time      `json:"end" yaml:"end"`
+	DurationMS    int64          `json:"duration_ms" yaml:"duration_ms"`
+	Ports         []int          `json:"ports" yaml:"ports"`
+	Hosts         []documentHost `json:"hosts" yaml:"hosts"`
+}
+
+type documentHost struct {
+	Host      string           `json:"host" yaml:"host"`
+	Target    string           `json:"target" yaml:"target"`
+	Found     bool             `json:"found" yaml:"found"`
+	Wildcard  bool             `json:"wildcard" yaml:"wildcard"`
+	Addresses []string         `json:"addresses" yaml:"addresses"`
+	Address   string           `json:"address" yaml:"address"`
+	Latency   *documentLatency `json:"latency" yaml:"latency"`
+	Ports     []documentPort   `json:"ports" yaml:"ports"`
+}
+
+// documentLatency sums up the latency of the ports of a host, it is null when none answered
+type documentLatency struct {
+	MinMS float64 `json:"min_ms" yaml:"min_ms"`
+	AvgMS float64 `json:"avg_ms" yaml:"avg_ms"`
+	MaxMS float64 `json:"max_ms" yaml:"max_ms"`
+}
+
+type documentPort struct {
+	Port      int           `json:"port" yaml:"port"`
+	Protocol  string        `json:"protocol" yaml:"protocol"`
+	State     string        `json:"state" yaml:"state"`
+	Reason    string        `json:"reason" yaml:"reason"`
+	LatencyMS float64       `json:"latency_ms" yaml:"latency_ms"`
+	Banner    string        `json:"banner" yaml:"banner"`
+	Service   string        `json:"service" yaml:"service"`
+	Version   string        `json:"version" yaml:"version"`
+	TLS       *documentTLS  `json:"tls" yaml:"tls"`
+	HTTP      *documentHTTP `json:"http" yaml:"http"`
+	Canceled  bool          `json:"canceled" yaml:"canceled"`
+}
+
+type documentHTTP struct {
+	URL        string   `json:"url" yaml:"url"`
+	Redirects  []string `json:"redirects" yaml:"redirects"`
+	StatusCode int      `json:"status_code" yaml:"status_code"`
+	Server     string   `json:"server" yaml:"server"`
+	Title      string   `json:"title" yaml:"title"`
+}
+
+type documentTLS struct {
+	Version     string    `json:"version" yaml:"version"`
+	CipherSuite string    `json:"cipher_suite" yaml:"cipher_suite"`
+	Subject     string    `json:"subject" yaml:"subject"`
+	SANs        []string  `json:"sans" yaml:"sans"`
+	Issuer      string    `json:"issuer" yaml:"issuer"`
+	NotBefore   time.Time `json:"not_before" yaml:"not_before"`
+	NotAfter    time.Time `json:"not_after" yaml:"not_after"`
+	SelfSigned  bool      `json:"self_signed" yaml:"self_signed"`
+	Warnings    []string  `json:"warnings" yaml:"warnings"`
+}
+
+func newDocument(r Report) document {
+	doc := document{
+		SchemaVersion: SchemaVersion,
+		Start:         r.Start,
+		End:           r.End,
+		DurationMS:    r.End.Sub(r.Start).Milliseconds(),
+		Ports:         r.Ports,
+		Hosts:         make([]documentHost, 0, len(r.Results)),
+	}
+	if doc.Ports == nil {
+		doc.Ports = []int{}
+	}
+
+	for _, res := range r.Results {
+		h := documentHost{
+			Host:      res.Host,
+			Target:    res.Target,
+			Found:     !res.NotFound,
+			Wildcard:  res.Wildcard,
+			Addresses: res.Addrs,
+			Address:   res.Addr,
+			Latency:   newDocumentLatency(res.Latency()),
+			Ports:     make([]documentPort, 0, len(res.PortStates)),
+		}
+		if h.Addresses == nil {
+			h.Addresses = []string{}
+		}
+		for _, p := range res.PortStates {
+			h.Ports = append(h.Ports, documentPort{
+				Port:      p.Port,
+				Protocol:  portProtocol(p.Protocol),
+				State:     p.State.String(),
+				Reason:    p.Reason,
+				LatencyMS: milliseconds(p.Latency),
+				Banner:    p.Banner,
+				Service:   p.Service,
+				Version:   p.Version,
+				TLS:       newDocumentTLS(p.TLS),
+				HTTP:      newDocumentHTTP(p.HTTP),
+				Canceled:  p.Canceled,
+			})
+		}
+		doc.Hosts = append(doc.Hosts, h)
+	}
+	return doc
+}
+
+func newDocumentLatency(l scan.LatencySummary) *documentLatency {
+	if l.Ports == 0 {
+		return nil
+	}
+	return &documentLatency{MinMS: milliseconds(l.Min), AvgMS: milliseconds(l.Avg), MaxMS: milliseconds(l.Max)}
+}
+
+// milliseconds converts a duration to a number of milliseconds with a fractional part
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+func newDocumentTLS(t *scan.TLSInfo) *documentTLS {
+	if t == nil {
+		return nil
+	}
+	doc := &documentTLS{
+		Version:     t.Version,
+		CipherSuite: t.CipherSuite,
+		Subject:     t.Subject,
+		SANs:        t.SANs,
+		Issuer:      t.Issuer,
+		NotBefore:   t.NotBefore,
+		NotAfter:    t.NotAfter,
+		SelfSigned:  t.SelfSigned,
+		Warnings:    t.Warnings,
+	}
+	if doc.SANs == nil {
+		doc.SANs = []string{}
+	}
+	if doc.Warnings == nil {
+		doc.Warnings = []string{}
+	}
+	return doc
+}
+
+func (doc *documentTLS) info() *scan.TLSInfo {
+	if doc == nil {
+		return nil
+	}
+	t := &scan.TLSInfo{
+		Version:     doc.Version,
+		CipherSuite: doc.CipherSuite,
+		Subject:     doc.Subject,
+		Issuer:      doc.Issuer,
+		NotBefore:   doc.NotBefore,
+		NotAfter:    doc.NotAfter,
+		SelfSigned:  doc.SelfSigned,
+	}
+	if len(doc.SANs) > 0 {
+		t.SANs = doc.SANs
+	}
+	if len(doc.Warnings) > 0 {
+		t.Warnings = doc.Warnings
+	}
+	return t
+}
+
+func newDocumentHTTP(h *scan.HTTPInfo) *documentHTTP {
+	if h == nil {
+		return nil
+	}
+	doc := &documentHTTP{
+		URL:        h.URL,
+		Redirects:  h.Redirects,
+		StatusCode: h.StatusCode,
+		Server:     h.Server,
+		Title:      h.Title,
+	}
+	if doc.Redirects == nil {
+		doc.Redirects = []string{}
+	}
+	return doc
+}
+
+func (doc *documentHTTP) info() *scan.HTTPInfo {
+	if doc == nil {
+		return nil
+	}
+	h := &scan.HTTPInfo{
+		URL:        doc.URL,
+		StatusCode: doc.StatusCode,
+		Server:     doc.Server,
+		Title:      doc.Title,
+	}
+	if len(doc.Redirects) > 0 {
+		h.Redirects = doc.Redirects
+	}
+	return h
+}
+
+// ReadJSON reads back a report written by the JSON format
+func ReadJSON(in io.Reader) (Report, error) {
+	var doc document
+	if err := json.NewDecoder(in).Decode(&doc); err != nil {
+		return Report{}, err
+	}
+	if doc.SchemaVersion < 1 || doc.SchemaVersion > SchemaVersion {
+		return Report{}, fmt.Errorf("%w: %d", ErrUnsupportedSchema, doc.SchemaVersion)
+	}
+
+	r := Report{
+		Start:   doc.Start,
+		End:     doc.End,
+		Ports:   doc.Ports,
+		Results: make([]scan.Results, 0, len(doc.Hosts)),
+	}
+	for _, h := range doc.Hosts {
+		res := scan.Results{
+			Host:     h.Host,
+			Target:   h.Target,
+			NotFound: !h.Found,
+			Wildcard: h.Wildcard,
+			Addr:     h.Address,
+		}
+		if len(h.Addresses) > 0 {
+			res.Addrs = h.Addresses
+		}
+		for _, p := range h.Ports {
+			state, err := scan.ParseState(p.State)
+			if err != nil {
+				return Report{}, err
+			}
+			res.PortStates = append(res.PortStates, scan.PortState{
+				Port:     p.Port,
+				Protocol: portProtocol(p.Protocol),
+				State:    state,
+				Reason:   p.Reason,
+				Latency:  time.Duration(p.LatencyMS * float64(time.Millisecond)),
+				Banner:   p.Banner,
+				Service:  p.Service,
+				Version:  p.Version,
+				TLS:      p.TLS.info(),
+				HTTP:     p.HTTP.info(),
+				Canceled: p.Canceled,
+			})
+		}
+		r.Results = append(r.Results, res)
+	}
+	return r, nil
+}
+
+// JSON writes the report as an indented JSON document
+type JSON struct{}
+
+func (JSON) Format(out io.Writer, r Report) error {
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(newDocument(r))
+}
+
+// YAML writes the report as a YAML document with the same schema as JSON
+type YAML struct{}
+
+func (YAML) Format(out io.Writer, r Report) error {
+	enc := yaml.NewEncoder(out)
+	enc.SetIndent(2)
+	if err := enc.Encode(newDocument(r)); err != nil {
+		return err
+	}
+	return enc.Close()
+}
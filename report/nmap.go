@@ -0,0 +1,285 @@
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nguyenanhhao221/pScan/scan"
+)
+
+// nmapXMLOutputVersion is the version of the nmap XML format the document follows
+const nmapXMLOutputVersion = "1.05"
+
+type nmapRun struct {
+	XMLName          xml.Name     `xml:"nmaprun"`
+	Scanner          string       `xml:"scanner,attr"`
+	Start            int64        `xml:"start,attr"`
+	StartStr         string       `xml:"startstr,attr"`
+	XMLOutputVersion string       `xml:"xmloutputversion,attr"`
+	ScanInfo         nmapScanInfo `xml:"scaninfo"`
+	Hosts            []nmapHost   `xml:"host"`
+	RunStats         nmapRunStats `xml:"runstats"`
+}
+
+type nmapScanInfo struct {
+	Type        string `xml:"type,attr"`
+	Protocol    string `xml:"protocol,attr"`
+	NumServices int    `xml:"numservices,attr"`
+	Services    string `xml:"services,attr"`
+}
+
+type nmapHost struct {
+	StartTime int64          `xml:"starttime,attr"`
+	EndTime   int64          `xml:"endtime,attr"`
+	Status    nmapStatus     `xml:"status"`
+	Addresses []nmapAddress  `xml:"address"`
+	Hostnames []nmapHostname `xml:"hostnames>hostname"`
+	Ports     []nmapPort     `xml:"ports>port"`
+	Times     *nmapTimes     `xml:"times"`
+}
+
+// nmapTimes is the round-trip time of a host in microseconds, to is the probe timeout it leads to
+type nmapTimes struct {
+	SRTT   int64 `xml:"srtt,attr"`
+	RTTVar int64 `xml:"rttvar,attr"`
+	To     int64 `xml:"to,attr"`
+}
+
+type nmapStatus struct {
+	State  string `xml:"state,attr"`
+	Reason string `xml:"reason,attr"`
+}
+
+type nmapAddress struct {
+	Addr     string `xml:"addr,attr"`
+	AddrType string `xml:"addrtype,attr"`
+}
+
+type nmapHostname struct {
+	Name string `xml:"name,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type nmapPort struct {
+	Protocol string        `xml:"protocol,attr"`
+	PortID   int           `xml:"portid,attr"`
+	State    nmapPortState `xml:"state"`
+	Service  *nmapService  `xml:"service"`
+	Scripts  []nmapScript  `xml:"script"`
+}
+
+// nmapService is a detected service, the whole version pScan found goes in the product
+type nmapService struct {
+	Name    string `xml:"name,attr"`
+	Product string `xml:"product,attr,omitempty"`
+	Method  string `xml:"method,attr"`
+	Conf    int    `xml:"conf,attr"`
+}
+
+// nmapScript is the output of an nmap script, pScan reports banners the way the nmap banner script does
+type nmapScript struct {
+	ID     string `xml:"id,attr"`
+	Output string `xml:"output,attr"`
+}
+
+type nmapPortState struct {
+	State  string `xml:"state,attr"`
+	Reason string `xml:"reason,attr"`
+}
+
+type nmapRunStats struct {
+	Finished nmapFinished  `xml:"finished"`
+	Hosts    nmapHostStats `xml:"hosts"`
+}
+
+type nmapFinished struct {
+	Time     int64  `xml:"time,attr"`
+	TimeStr  string `xml:"timestr,attr"`
+	Elapsed  string `xml:"elapsed,attr"`
+	Summary  string `xml:"summary,attr"`
+	Exit     string `xml:"exit,attr"`
+	ErrorMsg string `xml:"errormsg,attr,omitempty"`
+}
+
+type nmapHostStats struct {
+	Up    int `xml:"up,attr"`
+	Down  int `xml:"down,attr"`
+	Total int `xml:"total,attr"`
+}
+
+// NmapXML writes the report as an nmap XML document, a connect or UDP scan in nmap terms,
+// so it can be read by tools built around nmap such as ndiff.
+// Hosts that could not be resolved have no address and are only counted as down,
+// ports that were not probed are left out
+type NmapXML struct{}
+
+func (NmapXML) Format(out io.Writer, r Report) error {
+	services := make([]string, 0, len(r.Ports))
+	for _, p := range r.Ports {
+		services = append(services, strconv.Itoa(p))
+	}
+
+	// A run scans every port with the same protocol
+	proto := scan.ProtocolTCP
+	for _, res := range r.Results {
+		if len(res.PortStates) > 0 {
+			proto = portProtocol(res.PortStates[0].Protocol)
+			break
+		}
+	}
+	scanType := "connect"
+	if proto == scan.ProtocolUDP {
+		scanType = "udp"
+	}
+
+	run := nmapRun{
+		Scanner:          "pscan",
+		Start:            r.Start.Unix(),
+		StartStr:         nmapTime(r.Start),
+		XMLOutputVersion: nmapXMLOutputVersion,
+		ScanInfo: nmapScanInfo{
+			Type:        scanType,
+			Protocol:    proto,
+			NumServices: len(r.Ports),
+			Services:    strings.Join(services, ","),
+		},
+	}
+
+	interrupted := false
+	for _, res := range r.Results {
+		if res.NotFound {
+			run.RunStats.Hosts.Down++
+			continue
+		}
+		run.RunStats.Hosts.Up++
+
+		h := nmapHost{
+			StartTime: r.Start.Unix(),
+			EndTime:   r.End.Unix(),
+			Status:    nmapStatus{State: "up", Reason: "user-set"},
+			Addresses: nmapAddresses(res),
+		}
+		if _, err := netip.ParseAddr(res.Host); err != nil {
+			h.Hostnames = []nmapHostname{{Name: res.Host, Type: "user"}}
+		}
+		for _, p := range res.PortStates {
+			if p.Canceled {
+				interrupted = true
+				continue
+			}
+			port := nmapPort{
+				Protocol: portProtocol(p.Protocol),
+				PortID:   p.Port,
+				State:    nmapPortState{State: p.State.String(), Reason: p.Reason},
+			}
+			if p.Service != "" {
+				port.Service = &nmapService{Name: p.Service, Product: p.Version, Method: "probed", Conf: 10}
+			}
+			if p.Banner != "" {
+				port.Scripts = append(port.Scripts, nmapScript{ID: "banner", Output: p.Banner})
+			}
+			if p.TLS != nil {
+				port.Scripts = append(port.Scripts, nmapScript{ID: "ssl-cert", Output: nmapCert(p.TLS)})
+			}
+			if p.HTTP != nil && p.HTTP.Title != "" {
+				port.Scripts = append(port.Scripts, nmapScript{ID: "http-title", Output: p.HTTP.Title})
+			}
+			if p.HTTP != nil && p.HTTP.Server != "" {
+				port.Scripts = append(port.Scripts, nmapScript{ID: "http-server-header", Output: p.HTTP.Server})
+			}
+			h.Ports = append(h.Ports, port)
+		}
+		h.Times = nmapHostTimes(res)
+		run.Hosts = append(run.Hosts, h)
+	}
+
+	stats := &run.RunStats
+	stats.Hosts.Total = stats.Hosts.Up + stats.Hosts.Down
+	stats.Finished = nmapFinished{
+		Time:    r.End.Unix(),
+		TimeStr: nmapTime(r.End),
+		Elapsed: fmt.Sprintf("%.2f", r.End.Sub(r.Start).Seconds()),
+		Summary: fmt.Sprintf("pScan done at %s; %d IP addresses (%d hosts up) scanned in %.2f seconds",
+			nmapTime(r.End), stats.Hosts.Total, stats.Hosts.Up, r.End.Sub(r.Start).Seconds()),
+		Exit: "success",
+	}
+	if interrupted {
+		stats.Finished.Exit = "error"
+		stats.Finished.ErrorMsg = "scan stopped before completion"
+	}
+
+	if _, err := io.WriteString(out, xml.Header+"<!DOCTYPE nmaprun>\n"); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(out)
+	enc.Indent("", "  ")
+	if err := enc.Encode(run); err != nil {
+		return err
+	}
+	_, err := io.WriteString(out, "\n")
+	return err
+}
+
+// nmapAddresses lists the addresses of a host, nmap only reports a single
+// address per host so the one the ports were probed on is used
+func nmapAddresses(res scan.Results) []nmapAddress {
+	addr := res.Addr
+	if addr == "" && len(res.Addrs) > 0 {
+		addr = res.Addrs[0]
+	}
+	if addr == "" {
+		addr = res.Host
+	}
+	ip, err := netip.ParseAddr(addr)
+	if err != nil {
+		return nil
+	}
+	addrType := "ipv4"
+	if ip.Is6() && !ip.Is4In6() {
+		addrType = "ipv6"
+	}
+	return []nmapAddress{{Addr: ip.String(), AddrType: addrType}}
+}
+
+// nmapHostTimes derives the round-trip time of a host from the latency of its ports: the
+// average, its mean deviation, and the timeout the scanner would derive from them.
+// It returns nil when no port answered
+func nmapHostTimes(res scan.Results) *nmapTimes {
+	lat := res.Latency()
+	if lat.Ports == 0 {
+		return nil
+	}
+	var dev time.Duration
+	for _, p := range res.PortStates {
+		if p.Latency > 0 {
+			dev += (p.Latency - lat.Avg).Abs()
+		}
+	}
+	dev /= time.Duration(lat.Ports)
+	to := min(max(lat.Avg+4*dev, scan.MinTimeout), scan.MaxTimeout)
+	return &nmapTimes{SRTT: lat.Avg.Microseconds(), RTTVar: dev.Microseconds(), To: to.Microseconds()}
+}
+
+// nmapTime formats t the way nmap does in its startstr and timestr attributes
+func nmapTime(t time.Time) string {
+	return t.Format("Mon Jan _2 15:04:05 2006")
+}
+
+// nmapCert describes a certificate the way the nmap ssl-cert script does
+func nmapCert(t *scan.TLSInfo) string {
+	lines := []string{"Subject: " + t.Subject}
+	if len(t.SANs) > 0 {
+		lines = append(lines, "Subject Alternative Name: "+strings.Join(t.SANs, ", "))
+	}
+	lines = append(lines,
+		"Issuer: "+t.Issuer,
+		"Not valid before: "+t.NotBefore.UTC().Format("2006-01-02T15:04:05"),
+		"Not valid after:  "+t.NotAfter.UTC().Format("2006-01-02T15:04:05"),
+	)
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,195 @@
+package report_test
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nguyenanhhao221/pScan/report"
+	"github.com/nguyenanhhao221/pScan/scan"
+)
+
+func TestNmapXML(t *testing.T) {
+	r := testReport()
+	r.Results = append(r.Results, scan.Results{
+		Host:   "10.0.0.1",
+		Target: "10.0.0.0/31",
+		PortStates: []scan.PortState{
+			{Port: 22, State: scan.StateFiltered, Reason: "no-response"},
+			{Port: 80, Canceled: true},
+		},
+	})
+
+	var out bytes.Buffer
+	if err := (report.NmapXML{}).Format(&out, r); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(out.String(), xml.Header+"<!DOCTYPE nmaprun>\n") {
+		t.Errorf("Expect XML header and doctype, got %q", out.String()[:50])
+	}
+
+	var doc struct {
+		Scanner  string `xml:"scanner,attr"`
+		Start    int64  `xml:"start,attr"`
+		ScanInfo struct {
+			Type     string `xml:"type,attr"`
+			Protocol string `xml:"protocol,attr"`
+			Services string `xml:"services,attr"`
+		} `xml:"scaninfo"`
+		Hosts []struct {
+			Status struct {
+				State string `xml:"state,attr"`
+			} `xml:"status"`
+			Addresses []struct {
+				Addr     string `xml:"addr,attr"`
+				AddrType string `xml:"addrtype,attr"`
+			} `xml:"address"`
+			Hostnames []struct {
+				Name string `xml:"name,attr"`
+			} `xml:"hostnames>hostname"`
+			Ports []struct {
+				Protocol string `xml:"protocol,attr"`
+				PortID   int    `xml:"portid,attr"`
+				State    struct {
+					State  string `xml:"state,attr"`
+					Reason string `xml:"reason,attr"`
+				} `xml:"state"`
+				Service *struct {
+					Name    string `xml:"name,attr"`
+					Product string `xml:"product,attr"`
+				} `xml:"service"`
+				Scripts []struct {
+					ID     string `xml:"id,attr"`
+					Output string `xml:"output,attr"`
+				} `xml:"script"`
+			} `xml:"ports>port"`
+			Times *struct {
+				SRTT   int64 `xml:"srtt,attr"`
+				RTTVar int64 `xml:"rttvar,attr"`
+				To     int64 `xml:"to,attr"`
+			} `xml:"times"`
+		} `xml:"host"`
+		RunStats struct {
+			Finished struct {
+				Time int64  `xml:"time,attr"`
+				Exit string `xml:"exit,attr"`
+			} `xml:"finished"`
+			Hosts struct {
+				Up    int `xml:"up,attr"`
+				Down  int `xml:"down,attr"`
+				Total int `xml:"total,attr"`
+			} `xml:"hosts"`
+		} `xml:"runstats"`
+	}
+	if err := xml.Unmarshal(out.Bytes(), &doc); err != nil {
+		t.Fatalf("Expect well formed XML, got %q:\n%s", err, out.String())
+	}
+
+	if doc.Scanner != "pscan" || doc.ScanInfo.Type != "connect" || doc.ScanInfo.Protocol != "tcp" || doc.ScanInfo.Services != "22,80" {
+		t.Errorf("Unexpected nmaprun attributes: %+v", doc)
+	}
+	if doc.Start != r.Start.Unix() || doc.RunStats.Finished.Time != r.End.Unix() {
+		t.Errorf("Expect start %d and end %d, got %d and %d", r.Start.Unix(), r.End.Unix(), doc.Start, doc.RunStats.Finished.Time)
+	}
+
+	// The unresolved host is left out, the canceled port makes the run an error
+	if len(doc.Hosts) != 2 {
+		t.Fatalf("Expect 2 hosts, got %d", len(doc.Hosts))
+	}
+	if doc.RunStats.Hosts.Up != 2 || doc.RunStats.Hosts.Down != 1 || doc.RunStats.Hosts.Total != 3 {
+		t.Errorf("Unexpected host stats: %+v", doc.RunStats.Hosts)
+	}
+	if doc.RunStats.Finished.Exit != "error" {
+		t.Errorf("Expect exit %q, got %q", "error", doc.RunStats.Finished.Exit)
+	}
+
+	local := doc.Hosts[0]
+	if local.Status.State != "up" || len(local.Addresses) != 1 || local.Addresses[0].Addr != "127.0.0.1" || local.Addresses[0].AddrType != "ipv4" {
+		t.Errorf("Unexpected localhost status or address: %+v", local)
+	}
+	if len(local.Hostnames) != 1 || local.Hostnames[0].Name != "localhost" {
+		t.Errorf("Expect hostname localhost, got %+v", local.Hostnames)
+	}
+
+	type port struct {
+		ID     int
+		State  string
+		Reason string
+	}
+	var got []port
+	for _, h := range doc.Hosts {
+		for _, p := range h.Ports {
+			if p.Protocol != "tcp" {
+				t.Errorf("Expect protocol tcp, got %q", p.Protocol)
+			}
+			got = append(got, port{p.PortID, p.State.State, p.State.Reason})
+		}
+	}
+	exp := []port{{22, "open", "syn-ack"}, {80, "closed", "conn-refused"}, {22, "filtered", "no-response"}}
+	if diff := cmp.Diff(exp, got); diff != "" {
+		t.Errorf("%s mismatch (-want +got):\n%s", t.Name(), diff)
+	}
+
+	if svc := local.Ports[0].Service; svc == nil || svc.Name != "ssh" || svc.Product != "OpenSSH_9.6 (protocol 2.0)" {
+		t.Errorf("Expect ssh service on port 22, got %+v", svc)
+	}
+	if local.Ports[1].Service != nil {
+		t.Errorf("Expect no service on port 80, got %+v", local.Ports[1].Service)
+	}
+
+	scripts := local.Ports[0].Scripts
+	if len(scripts) != 1 || scripts[0].ID != "banner" || scripts[0].Output != "SSH-2.0-OpenSSH_9.6" {
+		t.Errorf("Expect banner script on port 22, got %+v", scripts)
+	}
+
+	// Ports answered in 1.5ms and 0.5ms, the timeout is the least the scanner uses
+	if times := local.Times; times == nil || times.SRTT != 1000 || times.RTTVar != 500 || times.To != 100000 {
+		t.Errorf("Expect srtt 1000, rttvar 500 and to 100000, got %+v", times)
+	}
+	if doc.Hosts[1].Times != nil {
+		t.Errorf("Expect no times for a host that never answered, got %+v", doc.Hosts[1].Times)
+	}
+
+	if len(doc.Hosts[1].Hostnames) != 0 {
+		t.Errorf("Expect no hostname for an address, got %+v", doc.Hosts[1].Hostnames)
+	}
+}
+
+func TestNmapXMLUDP(t *testing.T) {
+	r := testReport()
+	r.Results = r.Results[:1]
+	r.Results[0].PortStates = []scan.PortState{
+		{Port: 53, Protocol: scan.ProtocolUDP, State: scan.StateOpenFiltered, Reason: "no-response"},
+	}
+
+	var out bytes.Buffer
+	if err := (report.NmapXML{}).Format(&out, r); err != nil {
+		t.Fatal(err)
+	}
+
+	var doc struct {
+		ScanInfo struct {
+			Type     string `xml:"type,attr"`
+			Protocol string `xml:"protocol,attr"`
+		} `xml:"scaninfo"`
+		Ports []struct {
+			Protocol string `xml:"protocol,attr"`
+			State    struct {
+				State string `xml:"state,attr"`
+			} `xml:"state"`
+		} `xml:"host>ports>port"`
+	}
+	if err := xml.Unmarshal(out.Bytes(), &doc); err != nil {
+		t.Fatalf("Expect well formed XML, got %q:\n%s", err, out.String())
+	}
+
+	if doc.ScanInfo.Type != "udp" || doc.ScanInfo.Protocol != "udp" {
+		t.Errorf("Expect a udp scan, got %+v", doc.ScanInfo)
+	}
+	if len(doc.Ports) != 1 || doc.Ports[0].Protocol != "udp" || doc.Ports[0].State.State != "open|filtered" {
+		t.Errorf("Expect port 53 udp open|filtered, got %+v", doc.Ports)
+	}
+}
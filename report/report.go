@@ -0,0 +1,91 @@
+// Package report renders scan results in the output formats supported by pScan
+package report
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nguyenanhhao221/pScan/scan"
+)
+
+var ErrUnknownFormat = errors.New("unknown output format")
+
+// Report is a single scan run, it may be partial if the scan was interrupted
+type Report struct {
+	Start   time.Time
+	End     time.Time
+	Ports   []int
+	Results []scan.Results
+}
+
+// Formatter writes a report to out in a specific format
+type Formatter interface {
+	Format(out io.Writer, r Report) error
+}
+
+// HostFormatter is a Formatter that can also write the results of a single host,
+// so they can be printed as soon as the host is scanned instead of once the scan is over
+type HostFormatter interface {
+	Formatter
+	FormatHost(out io.Writer, res scan.Results) error
+}
+
+var (
+	mu         sync.RWMutex
+	formatters = map[string]Formatter{
+		"text":     Text{},
+		"json":     JSON{},
+		"yaml":     YAML{},
+		"csv":      CSV{},
+		"nmap-xml": NmapXML{},
+	}
+)
+
+// Register makes a formatter available under name, replacing any formatter
+// previously registered with the same name
+func Register(name string, f Formatter) {
+	mu.Lock()
+	defer mu.Unlock()
+	formatters[name] = f
+}
+
+// Get returns the formatter registered under name
+func Get(name string) (Formatter, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+	f, ok := formatters[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q, expected one of %s", ErrUnknownFormat, name, strings.Join(names(), ", "))
+	}
+	return f, nil
+}
+
+// Names returns the sorted names of every registered formatter
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return names()
+}
+
+func names() []string {
+	n := make([]string, 0, len(formatters))
+	for name := range formatters {
+		n = append(n, name)
+	}
+	slices.Sort(n)
+	return n
+}
+
+// portProtocol returns the protocol a port was scanned with, ports from
+// reports written before UDP scanning existed were always scanned over TCP
+func portProtocol(name string) string {
+	if name == "" {
+		return scan.ProtocolTCP
+	}
+	return name
+}
@@ -0,0 +1,420 @@
+package report_test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nguyenanhhao221/pScan/report"
+	"github.com/nguyenanhhao221/pScan/scan"
+	"gopkg.in/yaml.v3"
+)
+
+func testReport() report.Report {
+	start := time.Date(2024, 10, 1, 12, 0, 0, 0, time.UTC)
+	return report.Report{
+		Start: start,
+		End:   start.Add(1500 * time.Millisecond),
+		Ports: []int{22, 80},
+		Results: []scan.Results{
+			{
+				Host:   "localhost",
+				Target: "localhost",
+				Addrs:  []string{"127.0.0.1"},
+				Addr:   "127.0.0.1",
+				PortStates: []scan.PortState{
+					{Port: 22, Protocol: scan.ProtocolTCP, State: scan.StateOpen, Reason: "syn-ack", Latency: 1500 * time.Microsecond, Banner: "SSH-2.0-OpenSSH_9.6", Service: "ssh", Version: "OpenSSH_9.6 (protocol 2.0)"},
+					{Port: 80, Protocol: scan.ProtocolTCP, State: scan.StateClosed, Reason: "conn-refused", Latency: 500 * time.Microsecond},
+				},
+			},
+			{Host: "invalidhost", Target: "invalidhost", NotFound: true},
+		},
+	}
+}
+
+func TestText(t *testing.T) {
+	var out bytes.Buffer
+	if err := (report.Text{}).Format(&out, testReport()); err != nil {
+		t.Fatal(err)
+	}
+
+	exp := "localhost:\n\t22: open ssh OpenSSH_9.6 (protocol 2.0) [SSH-2.0-OpenSSH_9.6] (1.5ms)\n\t80: closed (500µs)\n\tlatency: min 500µs, avg 1ms, max 1.5ms\n\ninvalidhost: Host not found\n\n"
+	if diff := cmp.Diff(exp, out.String()); diff != "" {
+		t.Errorf("%s mismatch (-want +got):\n%s", t.Name(), diff)
+	}
+}
+
+func TestTextFormatHost(t *testing.T) {
+	r := testReport()
+	var whole, hosts bytes.Buffer
+	if err := (report.Text{}).Format(&whole, r); err != nil {
+		t.Fatal(err)
+	}
+	for _, res := range r.Results {
+		if err := (report.Text{}).FormatHost(&hosts, res); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if diff := cmp.Diff(whole.String(), hosts.String()); diff != "" {
+		t.Errorf("%s mismatch (-want +got):\n%s", t.Name(), diff)
+	}
+}
+
+func TestTextUDP(t *testing.T) {
+	r := testReport()
+	r.Results = r.Results[:1]
+	r.Results[0].PortStates = []scan.PortState{
+		{Port: 53, Protocol: scan.ProtocolUDP, State: scan.StateOpen, Reason: "udp-response", Service: "dns"},
+		{Port: 123, Protocol: scan.ProtocolUDP, State: scan.StateOpenFiltered, Reason: "no-response"},
+		{Port: 161, Protocol: scan.ProtocolUDP, Canceled: true},
+	}
+
+	var out bytes.Buffer
+	if err := (report.Text{}).Format(&out, r); err != nil {
+		t.Fatal(err)
+	}
+
+	exp := "localhost:\n\t53/udp: open dns\n\t123/udp: open|filtered\n\t161/udp: not scanned\n\n"
+	if diff := cmp.Diff(exp, out.String()); diff != "" {
+		t.Errorf("%s mismatch (-want +got):\n%s", t.Name(), diff)
+	}
+}
+
+func TestTextWildcard(t *testing.T) {
+	r := testReport()
+	r.Results = r.Results[1:]
+	r.Results[0].Wildcard = true
+
+	var out bytes.Buffer
+	if err := (report.Text{}).Format(&out, r); err != nil {
+		t.Fatal(err)
+	}
+
+	exp := "invalidhost: Host not found (wildcard DNS answer)\n\n"
+	if diff := cmp.Diff(exp, out.String()); diff != "" {
+		t.Errorf("%s mismatch (-want +got):\n%s", t.Name(), diff)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	var out bytes.Buffer
+	if err := (report.JSON{}).Format(&out, testReport()); err != nil {
+		t.Fatal(err)
+	}
+
+	var doc map[string]any
+	if err := json.Unmarshal(out.Bytes(), &doc); err != nil {
+		t.Fatalf("Expect valid JSON, got %q: %s", err, out.String())
+	}
+
+	exp := map[string]any{
+		"schema_version": float64(report.SchemaVersion),
+		"start":          "2024-10-01T12:00:00Z",
+		"end":            "2024-10-01T12:00:01.5Z",
+		"duration_ms":    float64(1500),
+		"ports":          []any{float64(22), float64(80)},
+		"hosts": []any{
+			map[string]any{
+				"host":      "localhost",
+				"target":    "localhost",
+				"found":     true,
+				"wildcard":  false,
+				"addresses": []any{"127.0.0.1"},
+				"address":   "127.0.0.1",
+				"latency":   map[string]any{"min_ms": 0.5, "avg_ms": float64(1), "max_ms": 1.5},
+				"ports": []any{
+					map[string]any{"port": float64(22), "protocol": "tcp", "state": "open", "reason": "syn-ack", "latency_ms": 1.5, "banner": "SSH-2.0-OpenSSH_9.6", "service": "ssh", "version": "OpenSSH_9.6 (protocol 2.0)", "tls": nil, "http": nil, "canceled": false},
+					map[string]any{"port": float64(80), "protocol": "tcp", "state": "closed", "reason": "conn-refused", "latency_ms": 0.5, "banner": "", "service": "", "version": "", "tls": nil, "http": nil, "canceled": false},
+				},
+			},
+			map[string]any{
+				"host":      "invalidhost",
+				"target":    "invalidhost",
+				"found":     false,
+				"wildcard":  false,
+				"addresses": []any{},
+				"address":   "",
+				"latency":   nil,
+				"ports":     []any{},
+			},
+		},
+	}
+	if diff := cmp.Diff(exp, doc); diff != "" {
+		t.Errorf("%s mismatch (-want +got):\n%s", t.Name(), diff)
+	}
+}
+
+func TestYAML(t *testing.T) {
+	var out bytes.Buffer
+	if err := (report.YAML{}).Format(&out, testReport()); err != nil {
+		t.Fatal(err)
+	}
+
+	var doc struct {
+		SchemaVersion int `yaml:"schema_version"`
+		Hosts         []struct {
+			Host  string `yaml:"host"`
+			Ports []struct {
+				Port  int    `yaml:"port"`
+				State string `yaml:"state"`
+			} `yaml:"ports"`
+		} `yaml:"hosts"`
+	}
+	if err := yaml.Unmarshal(out.Bytes(), &doc); err != nil {
+		t.Fatalf("Expect valid YAML, got %q: %s", err, out.String())
+	}
+
+	if doc.SchemaVersion != report.SchemaVersion {
+		t.Errorf("Expect schema version %d, got %d", report.SchemaVersion, doc.SchemaVersion)
+	}
+	if len(doc.Hosts) != 2 || len(doc.Hosts[0].Ports) != 2 {
+		t.Fatalf("Expect 2 hosts with 2 ports on the first, got %+v", doc.Hosts)
+	}
+	if doc.Hosts[0].Ports[0].State != "open" || doc.Hosts[0].Ports[1].State != "closed" {
+		t.Errorf("Expect open and closed ports, got %+v", doc.Hosts[0].Ports)
+	}
+}
+
+// csvRows parses CSV output into one map per row, keyed by the header columns
+func csvRows(t *testing.T, out *bytes.Buffer) []map[string]string {
+	t.Helper()
+
+	records, err := csv.NewReader(out).ReadAll()
+	if err != nil {
+		t.Fatalf("Expect valid CSV, got %q", err)
+	}
+	if len(records) == 0 {
+		t.Fatal("Expect a CSV header")
+	}
+
+	var rows []map[string]string
+	for _, rec := range records[1:] {
+		row := map[string]string{}
+		for i, col := range records[0] {
+			row[col] = rec[i]
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+func TestCSV(t *testing.T) {
+	var out bytes.Buffer
+	if err := (report.CSV{}).Format(&out, testReport()); err != nil {
+		t.Fatal(err)
+	}
+
+	header := "host,target,found,wildcard,addresses,address,port,protocol,state,reason,latency_ms,canceled,banner,service,version,"
+	if !strings.HasPrefix(out.String(), header) {
+		t.Errorf("Expect header to start with %q, got %q", header, strings.SplitN(out.String(), "\n", 2)[0])
+	}
+
+	exp := []map[string]string{
+		{"host": "localhost", "found": "true", "addresses": "127.0.0.1", "address": "127.0.0.1", "port": "22", "protocol": "tcp", "state": "open", "reason": "syn-ack", "latency_ms": "1.500",
+			"banner": "SSH-2.0-OpenSSH_9.6", "service": "ssh", "version": "OpenSSH_9.6 (protocol 2.0)", "tls_version": "", "http_status": ""},
+		{"host": "localhost", "found": "true", "addresses": "127.0.0.1", "address": "127.0.0.1", "port": "80", "protocol": "tcp", "state": "closed", "reason": "conn-refused", "latency_ms": "0.500",
+			"banner": "", "service": "", "version": "", "tls_version": "", "http_status": ""},
+		{"host": "invalidhost", "found": "false", "addresses": "", "address": "", "port": "", "protocol": "", "state": "", "reason": "", "latency_ms": "",
+			"banner": "", "service": "", "version": "", "tls_version": "", "http_status": ""},
+	}
+	rows := csvRows(t, &out)
+	if len(rows) != len(exp) {
+		t.Fatalf("Expect %d rows, got %d", len(exp), len(rows))
+	}
+	for i := range exp {
+		for col, v := range exp[i] {
+			if rows[i][col] != v {
+				t.Errorf("Expect row %d column %s to be %q, got %q", i, col, v, rows[i][col])
+			}
+		}
+	}
+}
+
+func TestTLS(t *testing.T) {
+	r := testReport()
+	notAfter := time.Date(2025, 1, 31, 0, 0, 0, 0, time.UTC)
+	r.Results[0].PortStates[1] = scan.PortState{
+		Port:     443,
+		Protocol: scan.ProtocolTCP,
+		State:    scan.StateOpen,
+		Reason:   "syn-ack",
+		TLS: &scan.TLSInfo{
+			Version:     "TLS 1.3",
+			CipherSuite: "TLS_AES_128_GCM_SHA256",
+			Subject:     "CN=example.com",
+			SANs:        []string{"example.com", "www.example.com"},
+			Issuer:      "CN=Example CA",
+			NotBefore:   notAfter.AddDate(-1, 0, 0),
+			NotAfter:    notAfter,
+			Warnings:    []string{"certificate expires in 10 days on 2025-01-31"},
+		},
+	}
+
+	var out bytes.Buffer
+	if err := (report.Text{}).Format(&out, r); err != nil {
+		t.Fatal(err)
+	}
+	exp := "\t443: open\n" +
+		"\t\ttls: TLS 1.3 TLS_AES_128_GCM_SHA256\n" +
+		"\t\tsubject: CN=example.com\n" +
+		"\t\tsans: example.com, www.example.com\n" +
+		"\t\tissuer: CN=Example CA\n" +
+		"\t\texpires: 2025-01-31\n" +
+		"\t\twarning: certificate expires in 10 days on 2025-01-31\n"
+	if !strings.Contains(out.String(), exp) {
+		t.Errorf("Expect text output to contain:\n%s\ngot:\n%s", exp, out.String())
+	}
+
+	out.Reset()
+	if err := (report.CSV{}).Format(&out, r); err != nil {
+		t.Fatal(err)
+	}
+	expRow := map[string]string{
+		"port":          "443",
+		"tls_version":   "TLS 1.3",
+		"tls_cipher":    "TLS_AES_128_GCM_SHA256",
+		"tls_subject":   "CN=example.com",
+		"tls_sans":      "example.com www.example.com",
+		"tls_issuer":    "CN=Example CA",
+		"tls_not_after": "2025-01-31T00:00:00Z",
+		"tls_warnings":  "certificate expires in 10 days on 2025-01-31",
+	}
+	row := csvRows(t, &out)[1]
+	for col, v := range expRow {
+		if row[col] != v {
+			t.Errorf("Expect column %s to be %q, got %q", col, v, row[col])
+		}
+	}
+
+	out.Reset()
+	if err := (report.JSON{}).Format(&out, r); err != nil {
+		t.Fatal(err)
+	}
+	got, err := report.ReadJSON(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(r, got); diff != "" {
+		t.Errorf("%s mismatch (-want +got):\n%s", t.Name(), diff)
+	}
+}
+
+func TestHTTP(t *testing.T) {
+	r := testReport()
+	r.Results[0].PortStates[1] = scan.PortState{
+		Port:     80,
+		Protocol: scan.ProtocolTCP,
+		State:    scan.StateOpen,
+		Reason:   "syn-ack",
+		Service:  "http",
+		Version:  "nginx/1.25.3",
+		HTTP: &scan.HTTPInfo{
+			URL:        "http://localhost:80/",
+			Redirects:  []string{"http://localhost/home"},
+			StatusCode: 200,
+			Server:     "nginx/1.25.3",
+			Title:      "Welcome home",
+		},
+	}
+
+	var out bytes.Buffer
+	if err := (report.Text{}).Format(&out, r); err != nil {
+		t.Fatal(err)
+	}
+	exp := "\t80: open http nginx/1.25.3\n" +
+		"\t\thttp: 200 http://localhost:80/\n" +
+		"\t\tredirect: http://localhost/home\n" +
+		"\t\tserver: nginx/1.25.3\n" +
+		"\t\ttitle: Welcome home\n"
+	if !strings.Contains(out.String(), exp) {
+		t.Errorf("Expect text output to contain:\n%s\ngot:\n%s", exp, out.String())
+	}
+
+	out.Reset()
+	if err := (report.CSV{}).Format(&out, r); err != nil {
+		t.Fatal(err)
+	}
+	expRow := map[string]string{
+		"http_status":    "200",
+		"http_server":    "nginx/1.25.3",
+		"http_title":     "Welcome home",
+		"http_redirects": "http://localhost/home",
+	}
+	row := csvRows(t, &out)[1]
+	for col, v := range expRow {
+		if row[col] != v {
+			t.Errorf("Expect column %s to be %q, got %q", col, v, row[col])
+		}
+	}
+
+	out.Reset()
+	if err := (report.JSON{}).Format(&out, r); err != nil {
+		t.Fatal(err)
+	}
+	got, err := report.ReadJSON(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(r, got); diff != "" {
+		t.Errorf("%s mismatch (-want +got):\n%s", t.Name(), diff)
+	}
+}
+
+type nopFormatter struct{}
+
+func (nopFormatter) Format(io.Writer, report.Report) error { return nil }
+
+func TestRegistry(t *testing.T) {
+	for _, name := range []string{"text", "json", "yaml", "csv", "nmap-xml"} {
+		if _, err := report.Get(name); err != nil {
+			t.Errorf("Expect format %q to be registered, got %q", name, err)
+		}
+	}
+
+	if _, err := report.Get("foo"); !errors.Is(err, report.ErrUnknownFormat) {
+		t.Errorf("Expect error %q, got %v", report.ErrUnknownFormat, err)
+	}
+
+	report.Register("nop", nopFormatter{})
+	if _, err := report.Get("nop"); err != nil {
+		t.Errorf("Expect registered format, got %q", err)
+	}
+	if !slices.Contains(report.Names(), "nop") {
+		t.Errorf("Expect %v to contain %q", report.Names(), "nop")
+	}
+}
+
+func TestReadJSON(t *testing.T) {
+	r := testReport()
+	r.Results[0].PortStates = append(r.Results[0].PortStates, scan.PortState{Port: 443, Protocol: scan.ProtocolTCP, Canceled: true})
+	r.Results[1].Wildcard = true
+
+	var out bytes.Buffer
+	if err := (report.JSON{}).Format(&out, r); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := report.ReadJSON(&out)
+	if err != nil {
+		t.Fatalf("Expect no error, got %q", err)
+	}
+	if diff := cmp.Diff(r, got); diff != "" {
+		t.Errorf("%s mismatch (-want +got):\n%s", t.Name(), diff)
+	}
+
+	// Reports written before hosts were split by address are still read
+	if _, err := report.ReadJSON(strings.NewReader(`{"schema_version": 1}`)); err != nil {
+		t.Errorf("Expect schema version 1 to be read, got %q", err)
+	}
+	_, err = report.ReadJSON(strings.NewReader(`{"schema_version": 99}`))
+	if !errors.Is(err, report.ErrUnsupportedSchema) {
+		t.Errorf("Expect error %q, got %v", report.ErrUnsupportedSchema, err)
+	}
+}
@@ -0,0 +1,126 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nguyenanhhao221/pScan/scan"
+)
+
+// Text is the human readable format, one block per host with a line per port
+type Text struct{}
+
+func (Text) Format(out io.Writer, r Report) error {
+	var message string
+	for _, res := range r.Results {
+		message += textHost(res)
+	}
+	_, err := fmt.Fprint(out, message)
+	return err
+}
+
+func (Text) FormatHost(out io.Writer, res scan.Results) error {
+	_, err := fmt.Fprint(out, textHost(res))
+	return err
+}
+
+// textHost describes a host in a block ending with an empty line
+func textHost(res scan.Results) string {
+	name := res.Name()
+	if res.Target != "" && res.Target != res.Host {
+		name = fmt.Sprintf("%s (%s)", res.Host, res.Target)
+	}
+	message := fmt.Sprintf("%s:", name)
+	if res.NotFound && res.Wildcard {
+		message += fmt.Sprintln(" Host not found (wildcard DNS answer)")
+		message += fmt.Sprintln()
+		return message
+	}
+	if res.NotFound {
+		message += fmt.Sprintln(" Host not found")
+		message += fmt.Sprintln()
+		return message
+	}
+	message += fmt.Sprintln()
+
+	for _, p := range res.PortStates {
+		if p.Canceled {
+			message += fmt.Sprintf("\t%s: not scanned\n", textPort(p))
+			continue
+		}
+		message += fmt.Sprintf("\t%s: %s", textPort(p), p.State.String())
+		if p.State == scan.StateError {
+			message += fmt.Sprintf(" (%s)", p.Reason)
+		}
+		if p.Service != "" {
+			message += " " + strings.TrimSpace(p.Service+" "+p.Version)
+		}
+		if p.Banner != "" {
+			message += fmt.Sprintf(" [%s]", p.Banner)
+		}
+		if p.Latency > 0 {
+			message += fmt.Sprintf(" (%s)", textLatency(p.Latency))
+		}
+		message += fmt.Sprintln()
+		if p.TLS != nil {
+			message += textTLS(p.TLS)
+		}
+		if p.HTTP != nil {
+			message += textHTTP(p.HTTP)
+		}
+	}
+	if l := res.Latency(); l.Ports > 0 {
+		message += fmt.Sprintf("\tlatency: min %s, avg %s, max %s\n", textLatency(l.Min), textLatency(l.Avg), textLatency(l.Max))
+	}
+	message += fmt.Sprintln()
+	return message
+}
+
+// textLatency rounds a latency to a precision that reads well next to the others
+func textLatency(d time.Duration) string {
+	if d >= time.Millisecond {
+		return d.Round(10 * time.Microsecond).String()
+	}
+	return d.Round(time.Microsecond).String()
+}
+
+// textPort names a port, UDP ports get a suffix so they stand out from the usual TCP ports
+func textPort(p scan.PortState) string {
+	if portProtocol(p.Protocol) == scan.ProtocolTCP {
+		return strconv.Itoa(p.Port)
+	}
+	return fmt.Sprintf("%d/%s", p.Port, p.Protocol)
+}
+
+// textTLS describes a TLS certificate below the line of its port
+func textTLS(t *scan.TLSInfo) string {
+	message := fmt.Sprintf("\t\ttls: %s %s\n", t.Version, t.CipherSuite)
+	message += fmt.Sprintf("\t\tsubject: %s\n", t.Subject)
+	if len(t.SANs) > 0 {
+		message += fmt.Sprintf("\t\tsans: %s\n", strings.Join(t.SANs, ", "))
+	}
+	message += fmt.Sprintf("\t\tissuer: %s\n", t.Issuer)
+	message += fmt.Sprintf("\t\texpires: %s\n", t.NotAfter.Format(time.DateOnly))
+	for _, w := range t.Warnings {
+		message += fmt.Sprintf("\t\twarning: %s\n", w)
+	}
+	return message
+}
+
+// textHTTP describes what an HTTP port serves below the line of its port
+func textHTTP(h *scan.HTTPInfo) string {
+	message := fmt.Sprintf("\t\thttp: %d %s\n", h.StatusCode, h.URL)
+	for _, r := range h.Redirects {
+		message += fmt.Sprintf("\t\tredirect: %s\n", r)
+	}
+	if h.Server != "" {
+		message += fmt.Sprintf("\t\tserver: %s\n", h.Server)
+	}
+	if h.Title != "" {
+		message += fmt.Sprintf("\t\ttitle: %s\n", h.Title)
+	}
+	return message
+}
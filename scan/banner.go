@@ -0,0 +1,82 @@
+package scan
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// DefaultBannerTimeout is how long to wait for a service to send its banner
+	// when Options.BannerTimeout is not set
+	DefaultBannerTimeout = 2 * time.Second
+	// DefaultBannerSize is the most bytes read from a banner when Options.BannerSize is not set
+	DefaultBannerSize = 256
+)
+
+// readResponse reads what a service sends on conn until done reports the data is
+// complete, size bytes are read, the connection is closed or timeout expires
+func readResponse(ctx context.Context, conn net.Conn, timeout time.Duration, size int, done func([]byte) bool) []byte {
+	if timeout <= 0 {
+		timeout = DefaultBannerTimeout
+	}
+	if size <= 0 {
+		size = DefaultBannerSize
+	}
+
+	// Unblock the read as soon as the scan is canceled
+	stop := context.AfterFunc(ctx, func() { conn.SetReadDeadline(time.Now()) })
+	defer stop()
+
+	if err := conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		return nil
+	}
+
+	buf := make([]byte, size)
+	n := 0
+	for n < size {
+		m, err := conn.Read(buf[n:])
+		n += m
+		if err != nil || done(buf[:n]) {
+			break
+		}
+	}
+	return buf[:n]
+}
+
+// endOfLine reports whether b holds a full line, most services send their
+// whole banner in one line so there is no need to wait for the timeout
+func endOfLine(b []byte) bool {
+	return bytes.IndexByte(b, '\n') >= 0
+}
+
+// printable turns raw banner bytes into a single line of text, trimming the
+// surrounding white space and escaping anything that is not printable
+func printable(b []byte) string {
+	var sb strings.Builder
+	s := strings.TrimSpace(string(b))
+	for len(s) > 0 {
+		r, size := utf8.DecodeRuneInString(s)
+		switch {
+		case r == '\n':
+			sb.WriteString(`\n`)
+		case r == '\r':
+			sb.WriteString(`\r`)
+		case r == '\t':
+			sb.WriteString(`\t`)
+		case r == utf8.RuneError && size == 1, !unicode.IsPrint(r):
+			for _, c := range []byte(s[:size]) {
+				fmt.Fprintf(&sb, `\x%02x`, c)
+			}
+		default:
+			sb.WriteRune(r)
+		}
+		s = s[size:]
+	}
+	return sb.String()
+}
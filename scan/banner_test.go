@@ -0,0 +1,93 @@
+package scan_test
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/nguyenanhhao221/pScan/scan"
+)
+
+// serve accepts connections on a local port and writes greeting to each of them
+func serve(t *testing.T, greeting string) int {
+	t.Helper()
+
+	return serveFunc(t, func(conn net.Conn) {
+		conn.Write([]byte(greeting))
+		// Keep the connection open so the scanner stops reading on its own
+		time.Sleep(time.Second)
+	})
+}
+
+// serveFunc accepts connections on a local port and hands each of them to handle
+func serveFunc(t *testing.T, handle func(conn net.Conn)) int {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", net.JoinHostPort("localhost", "0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				handle(conn)
+			}()
+		}
+	}()
+
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+func TestBanner(t *testing.T) {
+	testCases := []struct {
+		name     string
+		greeting string
+		size     int
+		exp      string
+	}{
+		{name: "SSH", greeting: "SSH-2.0-OpenSSH_9.6\r\n", exp: "SSH-2.0-OpenSSH_9.6"},
+		{name: "Silent", greeting: "", exp: ""},
+		{name: "Truncated", greeting: "220 mail.example.com ESMTP\r\n", size: 8, exp: "220 mail"},
+		{name: "NotPrintable", greeting: "\x00\x01ok\xff\tmore\n", exp: `\x00\x01ok\xff\tmore`},
+	}
+
+	hl := &scan.HostList{}
+	if err := hl.Add("127.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			port := serve(t, tc.greeting)
+			opts := scan.Options{Banner: true, BannerTimeout: 200 * time.Millisecond, BannerSize: tc.size}
+
+			res := scan.RunOptions(hl, []int{port}, opts)
+			if len(res) != 1 || len(res[0].PortStates) != 1 {
+				t.Fatalf("Expected 1 port state, got %+v\n", res)
+			}
+
+			ps := res[0].PortStates[0]
+			if ps.State != scan.StateOpen {
+				t.Errorf("Expect port %d to be open, got %s\n", port, ps.State)
+			}
+			if ps.Banner != tc.exp {
+				t.Errorf("Expect banner %q, got %q\n", tc.exp, ps.Banner)
+			}
+		})
+	}
+
+	t.Run("Disabled", func(t *testing.T) {
+		port := serve(t, "SSH-2.0-OpenSSH_9.6\r\n")
+		res := scan.RunOptions(hl, []int{port}, scan.Options{})
+		if b := res[0].PortStates[0].Banner; b != "" {
+			t.Errorf("Expect no banner without Options.Banner, got %q\n", b)
+		}
+	})
+}
@@ -9,15 +9,64 @@ import (
 	"os"
 	"slices"
 	"sort"
+	"strconv"
+	"strings"
+	"unicode"
 )
 
 var (
-	ErrExists    = errors.New("host already exist in the list")
-	ErrNotExists = errors.New("host not in the list")
+	ErrExists       = errors.New("host already exist in the list")
+	ErrNotExists    = errors.New("host not in the list")
+	ErrInvalidHosts = errors.New("invalid hosts file line")
 )
 
+// HostList is the list of hosts to scan. In the hosts file each host is on its
+// own line, optionally followed by key=value attributes that fill its HostInfo:
+//
+//	web1.example.com ports=22,443 group=web tags=prod,eu owner=alice notes="public frontend"
+//
+// Values with spaces are quoted, empty lines and lines starting with # are ignored
 type HostList struct {
 	Hosts []string
+	// Info holds the attributes of the hosts that have any, keyed by host
+	Info map[string]HostInfo
+}
+
+// HostInfo is what the hosts file records about a host besides its name
+type HostInfo struct {
+	// Ports is a port spec scanned on this host instead of the ports of the scan
+	Ports string
+	// Group is the single group the host belongs to, Tags are free form labels
+	Group string
+	Tags  []string
+	Owner string
+	Notes string
+}
+
+// IsZero reports whether the host has no attributes
+func (i HostInfo) IsZero() bool {
+	return i.Ports == "" && i.Group == "" && len(i.Tags) == 0 && i.Owner == "" && i.Notes == ""
+}
+
+// String formats the attributes the way they are written in the hosts file
+func (i HostInfo) String() string {
+	var attrs []string
+	add := func(key, value string) {
+		if value == "" {
+			return
+		}
+		// Control characters are quoted too, a newline would start another entry
+		if strings.ContainsAny(value, " \t\"\\#") || strings.ContainsFunc(value, unicode.IsControl) {
+			value = strconv.Quote(value)
+		}
+		attrs = append(attrs, key+"="+value)
+	}
+	add("ports", i.Ports)
+	add("group", i.Group)
+	add("tags", strings.Join(i.Tags, ","))
+	add("owner", i.Owner)
+	add("notes", i.Notes)
+	return strings.Join(attrs, " ")
 }
 
 func (hl *HostList) search(host string) (bool, int) {
@@ -43,16 +92,55 @@ func (hl *HostList) Remove(host string) error {
 	found, i := hl.search(host)
 	if found {
 		hl.Hosts = slices.Delete(hl.Hosts, i, i+1)
+		delete(hl.Info, host)
 		return nil
 	}
 	return ErrNotExists
 }
 
+// Select returns a list of the hosts for which keep returns true, along with their attributes
+func (hl *HostList) Select(keep func(host string, info HostInfo) bool) *HostList {
+	selected := &HostList{}
+	for _, host := range hl.Hosts {
+		info, ok := hl.Info[host]
+		if !keep(host, info) {
+			continue
+		}
+		selected.Hosts = append(selected.Hosts, host)
+		if ok {
+			if selected.Info == nil {
+				selected.Info = map[string]HostInfo{}
+			}
+			selected.Info[host] = info
+		}
+	}
+	return selected
+}
+
+// SetInfo replaces the attributes of a host in the list
+func (hl *HostList) SetInfo(host string, info HostInfo) error {
+	if found, _ := hl.search(host); !found {
+		return ErrNotExists
+	}
+	if info.IsZero() {
+		delete(hl.Info, host)
+		return nil
+	}
+	if hl.Info == nil {
+		hl.Info = map[string]HostInfo{}
+	}
+	hl.Info[host] = info
+	return nil
+}
+
 func (hl *HostList) Save(hostFile string) error {
 	var output string
 	for _, host := range hl.Hosts {
+		if info := hl.Info[host]; !info.IsZero() {
+			output += fmt.Sprintln(host, info)
+			continue
+		}
 		output += fmt.Sprintln(host)
-
 	}
 	return os.WriteFile(hostFile, []byte(output), 0644)
 }
@@ -69,9 +157,83 @@ func (hl *HostList) Load(hostFile string) error {
 	defer f.Close()
 
 	scanner := bufio.NewScanner(f)
-	for scanner.Scan() {
-		hl.Hosts = append(hl.Hosts, scanner.Text())
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		host, attrs := line, ""
+		if i := strings.IndexAny(line, " \t"); i >= 0 {
+			host, attrs = line[:i], line[i:]
+		}
+		info, err := parseHostInfo(attrs)
+		if err != nil {
+			return fmt.Errorf("%w %d: %w", ErrInvalidHosts, n, err)
+		}
+		hl.Hosts = append(hl.Hosts, host)
+		if !info.IsZero() {
+			if hl.Info == nil {
+				hl.Info = map[string]HostInfo{}
+			}
+			hl.Info[host] = info
+		}
 	}
 
-	return nil
+	return scanner.Err()
+}
+
+// parseHostInfo parses the key=value attributes following a host in the hosts file
+func parseHostInfo(s string) (HostInfo, error) {
+	var info HostInfo
+	for {
+		s = strings.TrimLeft(s, " \t")
+		if s == "" {
+			return info, nil
+		}
+
+		key, rest, ok := strings.Cut(s, "=")
+		if !ok || key == "" || strings.ContainsAny(key, " \t") {
+			return info, fmt.Errorf("expected key=value, got %q", s)
+		}
+
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			quoted, err := strconv.QuotedPrefix(rest)
+			if err != nil {
+				return info, fmt.Errorf("unterminated quoted value for %s", key)
+			}
+			value, _ = strconv.Unquote(quoted)
+			rest = rest[len(quoted):]
+		} else {
+			end := strings.IndexAny(rest, " \t")
+			if end < 0 {
+				end = len(rest)
+			}
+			value, rest = rest[:end], rest[end:]
+		}
+		s = rest
+
+		switch key {
+		case "ports":
+			if _, err := ParsePorts(value); err != nil {
+				return info, err
+			}
+			info.Ports = value
+		case "group":
+			info.Group = value
+		case "tags":
+			for _, tag := range strings.Split(value, ",") {
+				if tag = strings.TrimSpace(tag); tag != "" {
+					info.Tags = append(info.Tags, tag)
+				}
+			}
+		case "owner":
+			info.Owner = value
+		case "notes":
+			info.Notes = value
+		default:
+			return info, fmt.Errorf("unknown attribute %q", key)
+		}
+	}
 }
@@ -3,9 +3,11 @@ package scan_test
 import (
 	"errors"
 	"os"
+	"path/filepath"
 	"slices"
 	"testing"
 
+	"github.com/google/go-cmp/cmp"
 	"github.com/nguyenanhhao221/pScan/scan"
 )
 
@@ -129,3 +131,135 @@ func TestLoadNoFile(t *testing.T) {
 		t.Errorf("Expect no error, got %q instead \n", err)
 	}
 }
+
+func TestSaveLoadInfo(t *testing.T) {
+	hl1 := &scan.HostList{}
+	for _, h := range []string{"web1", "db1", "10.0.0.0/24"} {
+		if err := hl1.Add(h); err != nil {
+			t.Fatal(err)
+		}
+	}
+	web := scan.HostInfo{Ports: "22,443", Group: "web", Tags: []string{"prod", "eu"}, Owner: "alice", Notes: `public "frontend"`}
+	if err := hl1.SetInfo("web1", web); err != nil {
+		t.Fatal(err)
+	}
+	if err := hl1.SetInfo("missing", web); !errors.Is(err, scan.ErrNotExists) {
+		t.Errorf("Expect error %q, got %v", scan.ErrNotExists, err)
+	}
+
+	hostFile := filepath.Join(t.TempDir(), "pScan.hosts")
+	if err := hl1.Save(hostFile); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(hostFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := "10.0.0.0/24\ndb1\nweb1 ports=22,443 group=web tags=prod,eu owner=alice notes=\"public \\\"frontend\\\"\"\n"
+	if string(data) != exp {
+		t.Errorf("Expect hosts file %q, got %q", exp, string(data))
+	}
+
+	hl2 := &scan.HostList{}
+	if err := hl2.Load(hostFile); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(hl1, hl2); diff != "" {
+		t.Errorf("%s mismatch (-want +got):\n%s", t.Name(), diff)
+	}
+}
+
+func TestSaveLoadInfoControl(t *testing.T) {
+	hl1 := &scan.HostList{}
+	if err := hl1.Add("web1"); err != nil {
+		t.Fatal(err)
+	}
+	// Values must not break out of their line and add entries to the list
+	info := scan.HostInfo{Owner: "alice\rbob", Notes: "line1\n10.0.0.0/8\x00"}
+	if err := hl1.SetInfo("web1", info); err != nil {
+		t.Fatal(err)
+	}
+
+	hostFile := filepath.Join(t.TempDir(), "pScan.hosts")
+	if err := hl1.Save(hostFile); err != nil {
+		t.Fatal(err)
+	}
+	hl2 := &scan.HostList{}
+	if err := hl2.Load(hostFile); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(hl1, hl2); diff != "" {
+		t.Errorf("%s mismatch (-want +got):\n%s", t.Name(), diff)
+	}
+}
+
+func TestLoadInfo(t *testing.T) {
+	testCases := []struct {
+		name   string
+		file   string
+		exp    *scan.HostList
+		expErr error
+	}{
+		{
+			name: "Plain",
+			file: "host1\nhost2\n",
+			exp:  &scan.HostList{Hosts: []string{"host1", "host2"}},
+		},
+		{
+			name: "CommentsAndBlankLines",
+			file: "# web servers\n\nweb1\towner=bob   tags=web\n  db1  \n",
+			exp: &scan.HostList{
+				Hosts: []string{"web1", "db1"},
+				Info:  map[string]scan.HostInfo{"web1": {Owner: "bob", Tags: []string{"web"}}},
+			},
+		},
+		{name: "UnknownAttribute", file: "web1 color=blue\n", expErr: scan.ErrInvalidHosts},
+		{name: "NotKeyValue", file: "web1 prod\n", expErr: scan.ErrInvalidHosts},
+		{name: "InvalidPorts", file: "web1 ports=0\n", expErr: scan.ErrInvalidPort},
+		{name: "Unterminated", file: "web1 notes=\"oops\n", expErr: scan.ErrInvalidHosts},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			hostFile := filepath.Join(t.TempDir(), "pScan.hosts")
+			if err := os.WriteFile(hostFile, []byte(tc.file), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			hl := &scan.HostList{}
+			err := hl.Load(hostFile)
+			if tc.expErr != nil {
+				if !errors.Is(err, tc.expErr) {
+					t.Errorf("Expect error %q, got %v", tc.expErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expect no error, got %q", err)
+			}
+			if diff := cmp.Diff(tc.exp, hl); diff != "" {
+				t.Errorf("%s mismatch (-want +got):\n%s", t.Name(), diff)
+			}
+		})
+	}
+}
+
+func TestSelect(t *testing.T) {
+	hl := &scan.HostList{
+		Hosts: []string{"db1", "plain", "web1"},
+		Info: map[string]scan.HostInfo{
+			"db1":  {Group: "db", Tags: []string{"prod"}},
+			"web1": {Group: "web", Tags: []string{"prod"}},
+		},
+	}
+
+	got := hl.Select(func(host string, info scan.HostInfo) bool { return info.Group != "db" })
+	exp := &scan.HostList{
+		Hosts: []string{"plain", "web1"},
+		Info:  map[string]scan.HostInfo{"web1": {Group: "web", Tags: []string{"prod"}}},
+	}
+	if diff := cmp.Diff(exp, got); diff != "" {
+		t.Errorf("%s mismatch (-want +got):\n%s", t.Name(), diff)
+	}
+}
@@ -0,0 +1,142 @@
+package scan
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"html"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultHTTPTimeout limits an HTTP probe, redirects included, when Options.HTTPTimeout is not set
+	DefaultHTTPTimeout = 5 * time.Second
+	// maxRedirects is how many redirects an HTTP probe follows before it stops
+	maxRedirects = 10
+	// maxTitleBody is how much of the page is searched for its title
+	maxTitleBody = 64 << 10
+)
+
+var titlePattern = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+
+// HTTPInfo describes what an open HTTP port serves at /
+type HTTPInfo struct {
+	// URL is the first URL requested, Redirects lists the URLs it redirected to in order.
+	// Redirects off the scheme, host and port probed are listed but not followed
+	URL        string
+	Redirects  []string
+	StatusCode int
+	Server     string
+	Title      string
+}
+
+// probeHTTP sends a GET for / to an HTTP or HTTPS port and follows its redirects as long
+// as they stay on the port, so no request goes to a host outside the scan. The details
+// are those of the last response, a redirect that fails keeps those of the one before.
+// It returns nil when the port does not answer HTTP
+func (s *Scanner) probeHTTP(ctx context.Context, scheme, host, addr string, port int) *HTTPInfo {
+	timeout := s.HTTPTimeout
+	if timeout <= 0 {
+		timeout = DefaultHTTPTimeout
+	}
+	handshakeTimeout := s.BannerTimeout
+	if handshakeTimeout <= 0 {
+		handshakeTimeout = DefaultBannerTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	origin := net.JoinHostPort(host, strconv.Itoa(port))
+	info := &HTTPInfo{URL: fmt.Sprintf("%s://%s/", scheme, origin)}
+	// The URL keeps the host name for the Host header and SNI, connections to it go to addr
+	dial := func(ctx context.Context, network, address string) (net.Conn, error) {
+		if address == origin {
+			address = dialAddress(host, addr, port)
+		}
+		return s.dial(ctx, network, address)
+	}
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext:         dial,
+			TLSClientConfig:     &tls.Config{InsecureSkipVerify: true},
+			TLSHandshakeTimeout: handshakeTimeout,
+			DisableKeepAlives:   true,
+		},
+		// Redirects are followed below, once they are known to stay on the port
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	defer client.CloseIdleConnections()
+
+	target := info.URL
+	for hops := 0; ; hops++ {
+		resp, err := getHTTP(ctx, client, target)
+		if err != nil {
+			if hops == 0 {
+				return nil
+			}
+			return info
+		}
+		info.StatusCode, info.Server, info.Title = resp.status, resp.server, resp.title
+
+		if resp.location == nil {
+			return info
+		}
+		info.Redirects = append(info.Redirects, resp.location.String())
+		if hops >= maxRedirects || !sameOrigin(resp.location, scheme, origin) {
+			return info
+		}
+		target = resp.location.String()
+	}
+}
+
+// httpResponse is what a probe keeps of an HTTP response
+type httpResponse struct {
+	status int
+	server string
+	title  string
+	// location is where a redirect points to, nil for other responses
+	location *url.URL
+}
+
+// getHTTP sends a GET for target and reads the title of the page
+func getHTTP(ctx context.Context, client *http.Client, target string) (*httpResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "pScan")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	r := &httpResponse{status: resp.StatusCode, server: resp.Header.Get("Server")}
+	if resp.StatusCode >= 300 && resp.StatusCode < 400 {
+		r.location, _ = resp.Location()
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxTitleBody))
+	if m := titlePattern.FindSubmatch(body); m != nil {
+		r.title = strings.Join(strings.Fields(html.UnescapeString(string(m[1]))), " ")
+	}
+	return r, nil
+}
+
+// sameOrigin reports whether u points to the scheme and the host and port in origin
+func sameOrigin(u *url.URL, scheme, origin string) bool {
+	port := u.Port()
+	if port == "" {
+		port = map[string]string{"http": "80", "https": "443"}[u.Scheme]
+	}
+	return u.Scheme == scheme && net.JoinHostPort(u.Hostname(), port) == origin
+}
@@ -0,0 +1,161 @@
+package scan_test
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nguyenanhhao221/pScan/scan"
+)
+
+func TestHTTP(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/home", http.StatusFound)
+	})
+	mux.HandleFunc("/home", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Server", "nginx/1.25.3")
+		fmt.Fprint(w, "<html><head><TITLE>\n  Welcome &amp; hello\n</TITLE></head></html>")
+	})
+
+	plain := httptest.NewServer(mux)
+	defer plain.Close()
+	secure := httptest.NewTLSServer(mux)
+	defer secure.Close()
+
+	hl := &scan.HostList{}
+	if err := hl.Add("127.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name       string
+		port       int
+		scheme     string
+		expService string
+	}{
+		{"HTTP", plain.Listener.Addr().(*net.TCPAddr).Port, "http", "http"},
+		{"HTTPS", secure.Listener.Addr().(*net.TCPAddr).Port, "https", "https"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := scan.Options{HTTP: true, BannerTimeout: 200 * time.Millisecond}
+			res := scan.RunOptions(hl, []int{tc.port}, opts)
+			if len(res) != 1 || len(res[0].PortStates) != 1 {
+				t.Fatalf("Expected 1 port state, got %+v\n", res)
+			}
+
+			ps := res[0].PortStates[0]
+			if ps.Service != tc.expService {
+				t.Errorf("Expect service %q, got %q\n", tc.expService, ps.Service)
+			}
+			if ps.HTTP == nil {
+				t.Fatalf("Expect HTTP details for port %d\n", tc.port)
+			}
+
+			base := fmt.Sprintf("%s://127.0.0.1:%d", tc.scheme, tc.port)
+			exp := &scan.HTTPInfo{
+				URL:        base + "/",
+				Redirects:  []string{base + "/home"},
+				StatusCode: http.StatusOK,
+				Server:     "nginx/1.25.3",
+				Title:      "Welcome & hello",
+			}
+			if ps.HTTP.URL != exp.URL || !slices.Equal(ps.HTTP.Redirects, exp.Redirects) || ps.HTTP.StatusCode != exp.StatusCode ||
+				ps.HTTP.Server != exp.Server || ps.HTTP.Title != exp.Title {
+				t.Errorf("Expect %+v, got %+v\n", exp, ps.HTTP)
+			}
+		})
+	}
+
+	t.Run("NotHTTP", func(t *testing.T) {
+		port := serve(t, "SSH-2.0-OpenSSH_9.6\r\n")
+		res := scan.RunOptions(hl, []int{port}, scan.Options{HTTP: true, BannerTimeout: 200 * time.Millisecond})
+		if res[0].PortStates[0].HTTP != nil {
+			t.Errorf("Expect no HTTP details for an SSH port, got %+v\n", res[0].PortStates[0].HTTP)
+		}
+	})
+}
+
+func TestHTTPRedirects(t *testing.T) {
+	testCases := []struct {
+		name string
+		to   string
+	}{
+		// Redirects off the port are listed but no request is sent there
+		{name: "OtherHost", to: "http://other.test/"},
+		{name: "OtherScheme", to: "https://127.0.0.1/"},
+		// The redirect fails, the details of the response before it are kept
+		{name: "Broken", to: "/broken"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mux := http.NewServeMux()
+			mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Server", "edge")
+				http.Redirect(w, r, tc.to, http.StatusMovedPermanently)
+			})
+			mux.HandleFunc("/broken", func(w http.ResponseWriter, r *http.Request) {
+				conn, _, _ := http.NewResponseController(w).Hijack()
+				conn.Close()
+			})
+			server := httptest.NewServer(mux)
+			defer server.Close()
+			port := server.Listener.Addr().(*net.TCPAddr).Port
+			origin := net.JoinHostPort("127.0.0.1", strconv.Itoa(port))
+
+			d := &recordDialer{}
+			s := &scan.Scanner{Options: scan.Options{HTTP: true, BannerTimeout: 200 * time.Millisecond}, Dialer: d}
+			res, err := s.Run(context.Background(), &scan.HostList{Hosts: []string{"127.0.0.1"}}, []int{port})
+			if err != nil {
+				t.Fatalf("Expect no error, got %q", err)
+			}
+			info := res[0].PortStates[0].HTTP
+			if info == nil {
+				t.Fatal("Expect HTTP details")
+			}
+
+			expRedirects := []string{tc.to}
+			if strings.HasPrefix(tc.to, "/") {
+				expRedirects = []string{"http://" + origin + tc.to}
+			}
+			if info.StatusCode != http.StatusMovedPermanently || info.Server != "edge" || !slices.Equal(info.Redirects, expRedirects) {
+				t.Errorf("Expect a 301 from edge redirecting to %v, got %+v", expRedirects, info)
+			}
+			for _, address := range d.addresses() {
+				if address != origin {
+					t.Errorf("Expect every connection to go to the scanned port, got one to %s", address)
+				}
+			}
+		})
+	}
+}
+
+// recordDialer connects directly, recording the address of each connection
+type recordDialer struct {
+	mu     sync.Mutex
+	dialed []string
+}
+
+func (d *recordDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	d.mu.Lock()
+	d.dialed = append(d.dialed, address)
+	d.mu.Unlock()
+	return (&net.Dialer{}).DialContext(ctx, network, address)
+}
+
+func (d *recordDialer) addresses() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return slices.Clone(d.dialed)
+}
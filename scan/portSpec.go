@@ -0,0 +1,122 @@
+package scan
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	MinPort = 1
+	MaxPort = 65535
+)
+
+var (
+	ErrInvalidPort = errors.New("invalid port")
+	ErrNoPorts     = errors.New("port spec selects no ports")
+)
+
+// PortSets holds the named port sets that can be used in a port spec.
+// Callers can add their own sets before parsing
+var PortSets = map[string][]int{
+	"web": {80, 443, 8000, 8008, 8080, 8443, 8888},
+	"db":  {1433, 1521, 3306, 5432, 6379, 9042, 11211, 27017},
+	// The 100 most common TCP ports according to nmap-services
+	"top100": {
+		7, 9, 13, 21, 22, 23, 25, 26, 37, 53, 79, 80, 81, 88, 106, 110, 111, 113, 119, 135,
+		139, 143, 144, 179, 199, 389, 427, 443, 444, 445, 465, 513, 514, 515, 543, 544, 548, 554, 587, 631,
+		646, 873, 990, 993, 995, 1025, 1026, 1027, 1028, 1029, 1110, 1433, 1720, 1723, 1755, 1900, 2000, 2001, 2049, 2121,
+		2717, 3000, 3128, 3306, 3389, 3986, 4899, 5000, 5009, 5051, 5060, 5101, 5190, 5357, 5432, 5631, 5666, 5800, 5900, 6000,
+		6001, 6646, 7070, 8000, 8008, 8009, 8080, 8081, 8443, 8888, 9100, 9999, 10000, 32768, 49152, 49153, 49154, 49155, 49156, 49157,
+	},
+}
+
+// ParsePorts parses a comma separated port spec into a sorted list of unique ports.
+// Each item of the spec is either a single port ("22"), an inclusive range ("1-1024")
+// or the name of a set in PortSets ("web"). Prefixing an item with "!" excludes
+// its ports from the result, e.g. "1-1024,!25" or "top100,!db"
+func ParsePorts(spec string) ([]int, error) {
+	include := map[int]bool{}
+	exclude := map[int]bool{}
+
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		target := include
+		if name, ok := strings.CutPrefix(item, "!"); ok {
+			target = exclude
+			item = strings.TrimSpace(name)
+		}
+
+		ports, err := parsePortItem(item)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range ports {
+			target[p] = true
+		}
+	}
+
+	ports := make([]int, 0, len(include))
+	for p := range include {
+		if !exclude[p] {
+			ports = append(ports, p)
+		}
+	}
+	if len(ports) == 0 {
+		return nil, fmt.Errorf("%w: %q", ErrNoPorts, spec)
+	}
+
+	slices.Sort(ports)
+	return ports, nil
+}
+
+// parsePortItem expands a single item of a port spec, without the "!" prefix
+func parsePortItem(item string) ([]int, error) {
+	if set, ok := PortSets[strings.ToLower(item)]; ok {
+		return set, nil
+	}
+
+	if lo, hi, ok := strings.Cut(item, "-"); ok {
+		start, err := parsePort(lo)
+		if err != nil {
+			return nil, err
+		}
+		end, err := parsePort(hi)
+		if err != nil {
+			return nil, err
+		}
+		if start > end {
+			return nil, fmt.Errorf("%w: range %q starts after it ends", ErrInvalidPort, item)
+		}
+
+		ports := make([]int, 0, end-start+1)
+		for p := start; p <= end; p++ {
+			ports = append(ports, p)
+		}
+		return ports, nil
+	}
+
+	p, err := parsePort(item)
+	if err != nil {
+		return nil, err
+	}
+	return []int{p}, nil
+}
+
+func parsePort(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	p, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q is not a port number or a known port set", ErrInvalidPort, s)
+	}
+	if p < MinPort || p > MaxPort {
+		return 0, fmt.Errorf("%w: %d is out of range %d-%d", ErrInvalidPort, p, MinPort, MaxPort)
+	}
+	return p, nil
+}
@@ -0,0 +1,60 @@
+package scan_test
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/nguyenanhhao221/pScan/scan"
+)
+
+func TestParsePorts(t *testing.T) {
+	testCases := []struct {
+		name   string
+		spec   string
+		exp    []int
+		expErr error
+	}{
+		{name: "Single", spec: "22", exp: []int{22}},
+		{name: "List", spec: "443,22, 80", exp: []int{22, 80, 443}},
+		{name: "Range", spec: "20-25", exp: []int{20, 21, 22, 23, 24, 25}},
+		{name: "Duplicates", spec: "22,20-23,22", exp: []int{20, 21, 22, 23}},
+		{name: "Exclude", spec: "20-25,!22,!24-25", exp: []int{20, 21, 23}},
+		{name: "ExcludeBeforeInclude", spec: "!22,20-23", exp: []int{20, 21, 23}},
+		{name: "NamedSet", spec: "db", exp: []int{1433, 1521, 3306, 5432, 6379, 9042, 11211, 27017}},
+		{name: "NamedSetExclude", spec: "web,!8000-9000", exp: []int{80, 443}},
+		{name: "Top100", spec: "top100,!1-1000", exp: []int{
+			1025, 1026, 1027, 1028, 1029, 1110, 1433, 1720, 1723, 1755, 1900, 2000, 2001, 2049, 2121,
+			2717, 3000, 3128, 3306, 3389, 3986, 4899, 5000, 5009, 5051, 5060, 5101, 5190, 5357, 5432, 5631, 5666, 5800, 5900, 6000,
+			6001, 6646, 7070, 8000, 8008, 8009, 8080, 8081, 8443, 8888, 9100, 9999, 10000, 32768, 49152, 49153, 49154, 49155, 49156, 49157,
+		}},
+		{name: "Bounds", spec: "1,65535", exp: []int{1, 65535}},
+		{name: "Zero", spec: "0", expErr: scan.ErrInvalidPort},
+		{name: "TooHigh", spec: "65536", expErr: scan.ErrInvalidPort},
+		{name: "RangeTooHigh", spec: "65000-70000", expErr: scan.ErrInvalidPort},
+		{name: "ReversedRange", spec: "25-20", expErr: scan.ErrInvalidPort},
+		{name: "UnknownSet", spec: "foo", expErr: scan.ErrInvalidPort},
+		{name: "Empty", spec: "", expErr: scan.ErrNoPorts},
+		{name: "AllExcluded", spec: "22,!22", expErr: scan.ErrNoPorts},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ports, err := scan.ParsePorts(tc.spec)
+			if tc.expErr != nil {
+				if !errors.Is(err, tc.expErr) {
+					t.Errorf("Expect error: %q, got %q instead", tc.expErr, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Expect no error, got error: %q", err)
+			}
+
+			if !slices.Equal(tc.exp, ports) {
+				t.Errorf("Expect: %v, got %v\n", tc.exp, ports)
+			}
+		})
+	}
+}
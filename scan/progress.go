@@ -0,0 +1,32 @@
+package scan
+
+import "time"
+
+// Progress tells how far a scan has gone, it is handed to Scanner.OnProgress
+type Progress struct {
+	// Done counts the probes completed out of Total, Total is known once the hosts are looked up
+	Done  int
+	Total int
+	// Open counts the open ports found so far
+	Open int
+	// Elapsed is the time spent probing so far
+	Elapsed time.Duration
+}
+
+// Rate returns the number of probes completed per second
+func (p Progress) Rate() float64 {
+	if p.Elapsed <= 0 {
+		return 0
+	}
+	return float64(p.Done) / p.Elapsed.Seconds()
+}
+
+// ETA estimates the time left to complete the scan at the current rate,
+// zero when no probe has completed yet
+func (p Progress) ETA() time.Duration {
+	rate := p.Rate()
+	if rate == 0 {
+		return 0
+	}
+	return time.Duration(float64(p.Total-p.Done) / rate * float64(time.Second))
+}
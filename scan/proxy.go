@@ -0,0 +1,294 @@
+package scan
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// DefaultProxyTimeout is how long a connection through a proxy may take to be
+// established when ProxyDialer.Timeout is not set. The proxy has to reach the
+// port itself, so it gets more time than a direct connection
+const DefaultProxyTimeout = 5 * time.Second
+
+var (
+	ErrInvalidProxy = errors.New("invalid proxy")
+	// ErrProxy is returned when the proxy fails in a way that says nothing about the port
+	ErrProxy = errors.New("proxy error")
+)
+
+// ProxyDialer makes connections through a SOCKS5 or an HTTP CONNECT proxy.
+// Failures the proxy reports are turned into the errors a direct connection
+// would get, so a port the proxy could not reach is classified as closed or filtered
+type ProxyDialer struct {
+	// URL of the proxy, either "socks5://[user:password@]host:port" or "http://[user:password@]host:port"
+	URL *url.URL
+	// Timeout limits the connection to the proxy and the proxy's connection to the port,
+	// DefaultProxyTimeout is used when it is zero
+	Timeout time.Duration
+	// Forward makes the connection to the proxy, a net.Dialer is used when it is nil
+	Forward Dialer
+}
+
+// NewProxyDialer returns a ProxyDialer for the proxy at rawURL
+func NewProxyDialer(rawURL string) (*ProxyDialer, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidProxy, err)
+	}
+	switch u.Scheme {
+	case "socks5", "http":
+	default:
+		return nil, fmt.Errorf("%w: %q, expected a socks5:// or http:// URL", ErrInvalidProxy, rawURL)
+	}
+	if u.Port() == "" {
+		return nil, fmt.Errorf("%w: %q has no port", ErrInvalidProxy, rawURL)
+	}
+	return &ProxyDialer{URL: u}, nil
+}
+
+// DialContext connects to address through the proxy. Only TCP can go through a proxy
+func (d *ProxyDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	if network != ProtocolTCP {
+		return nil, fmt.Errorf("%w: %s cannot go through a proxy", ErrProxy, network)
+	}
+
+	timeout := d.Timeout
+	if timeout <= 0 {
+		timeout = DefaultProxyTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	forward := d.Forward
+	if forward == nil {
+		forward = &net.Dialer{}
+	}
+	conn, err := forward.DialContext(ctx, ProtocolTCP, d.URL.Host)
+	if err != nil {
+		// The proxy being unreachable must not make the port look closed
+		return nil, fmt.Errorf("%w: connecting to %s: %s", ErrProxy, d.URL.Host, err)
+	}
+
+	// Bound the handshake and unblock it as soon as ctx is done
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	if d.URL.Scheme == "http" {
+		conn, err = d.connectHTTP(conn, address)
+	} else {
+		err = d.connectSOCKS5(conn, address)
+	}
+	if err != nil {
+		conn.Close()
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			// The proxy did not answer in time, most likely because it is still waiting for the port
+			return nil, fmt.Errorf("%w: no answer within %s: %w", ErrProxy, timeout, os.ErrDeadlineExceeded)
+		}
+		return nil, err
+	}
+
+	if !stop() {
+		conn.Close()
+		return nil, ctx.Err()
+	}
+	conn.SetDeadline(time.Time{})
+	return conn, nil
+}
+
+// SOCKS5 reply codes, from RFC 1928
+var socks5Replies = map[byte]struct {
+	msg string
+	err error
+}{
+	0x01: {"general server failure", nil},
+	0x02: {"connection not allowed by ruleset", nil},
+	0x03: {"network unreachable", syscall.ENETUNREACH},
+	0x04: {"host unreachable", syscall.EHOSTUNREACH},
+	0x05: {"connection refused", syscall.ECONNREFUSED},
+	0x06: {"TTL expired", os.ErrDeadlineExceeded},
+	0x07: {"command not supported", nil},
+	0x08: {"address type not supported", nil},
+}
+
+// connectSOCKS5 asks a SOCKS5 proxy to connect conn to address
+func (d *ProxyDialer) connectSOCKS5(conn net.Conn, address string) error {
+	host, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return err
+	}
+
+	// Offer username and password authentication only when the URL has credentials
+	methods := []byte{0x00}
+	if d.URL.User != nil {
+		methods = []byte{0x02}
+	}
+	if _, err := conn.Write(append([]byte{0x05, byte(len(methods))}, methods...)); err != nil {
+		return fmt.Errorf("%w: %s", ErrProxy, err)
+	}
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return fmt.Errorf("%w: %s", ErrProxy, err)
+	}
+	if reply[0] != 0x05 || reply[1] != methods[0] {
+		return fmt.Errorf("%w: socks5 proxy rejected the authentication methods", ErrProxy)
+	}
+	if methods[0] == 0x02 {
+		if err := d.authSOCKS5(conn); err != nil {
+			return err
+		}
+	}
+
+	req := []byte{0x05, 0x01, 0x00}
+	if ip, err := netip.ParseAddr(host); err == nil {
+		if ip.Is4() {
+			req = append(req, 0x01)
+		} else {
+			req = append(req, 0x04)
+		}
+		req = append(req, ip.AsSlice()...)
+	} else {
+		if len(host) > 255 {
+			return fmt.Errorf("%w: host name %q is too long for socks5", ErrProxy, host)
+		}
+		// Host names are resolved by the proxy
+		req = append(req, 0x03, byte(len(host)))
+		req = append(req, host...)
+	}
+	req = binary.BigEndian.AppendUint16(req, uint16(port))
+	if _, err := conn.Write(req); err != nil {
+		return fmt.Errorf("%w: %s", ErrProxy, err)
+	}
+
+	// The reply ends with the address the proxy bound, whose length depends on its type
+	head := make([]byte, 4)
+	if _, err := io.ReadFull(conn, head); err != nil {
+		return fmt.Errorf("%w: %s", ErrProxy, err)
+	}
+	if head[1] != 0x00 {
+		r, ok := socks5Replies[head[1]]
+		if !ok {
+			return fmt.Errorf("%w: socks5 proxy replied with unknown code %d", ErrProxy, head[1])
+		}
+		if r.err != nil {
+			return fmt.Errorf("socks5 proxy: %s: %w", r.msg, r.err)
+		}
+		return fmt.Errorf("%w: socks5 proxy: %s", ErrProxy, r.msg)
+	}
+
+	var addrLen int
+	switch head[3] {
+	case 0x01:
+		addrLen = 4
+	case 0x04:
+		addrLen = 16
+	case 0x03:
+		l := make([]byte, 1)
+		if _, err := io.ReadFull(conn, l); err != nil {
+			return fmt.Errorf("%w: %s", ErrProxy, err)
+		}
+		addrLen = int(l[0])
+	default:
+		return fmt.Errorf("%w: socks5 proxy replied with unknown address type %d", ErrProxy, head[3])
+	}
+	if _, err := io.ReadFull(conn, make([]byte, addrLen+2)); err != nil {
+		return fmt.Errorf("%w: %s", ErrProxy, err)
+	}
+	return nil
+}
+
+// authSOCKS5 sends the credentials of the proxy URL, from RFC 1929
+func (d *ProxyDialer) authSOCKS5(conn net.Conn) error {
+	user := d.URL.User.Username()
+	pass, _ := d.URL.User.Password()
+	if len(user) > 255 || len(pass) > 255 {
+		return fmt.Errorf("%w: socks5 credentials are too long", ErrProxy)
+	}
+
+	req := []byte{0x01, byte(len(user))}
+	req = append(req, user...)
+	req = append(req, byte(len(pass)))
+	req = append(req, pass...)
+	if _, err := conn.Write(req); err != nil {
+		return fmt.Errorf("%w: %s", ErrProxy, err)
+	}
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return fmt.Errorf("%w: %s", ErrProxy, err)
+	}
+	if reply[1] != 0x00 {
+		return fmt.Errorf("%w: socks5 proxy rejected the credentials", ErrProxy)
+	}
+	return nil
+}
+
+// connectHTTP asks an HTTP proxy to connect conn to address with the CONNECT method.
+// Proxies only report failures with a status code: 502 and 503 are taken as the port
+// refusing the connection and 504 as the port not answering
+func (d *ProxyDialer) connectHTTP(conn net.Conn, address string) (net.Conn, error) {
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: address},
+		Host:   address,
+		Header: http.Header{},
+	}
+	if d.URL.User != nil {
+		pass, _ := d.URL.User.Password()
+		creds := base64.StdEncoding.EncodeToString([]byte(d.URL.User.Username() + ":" + pass))
+		req.Header.Set("Proxy-Authorization", "Basic "+creds)
+	}
+	if err := req.Write(conn); err != nil {
+		return conn, fmt.Errorf("%w: %s", ErrProxy, err)
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		return conn, fmt.Errorf("%w: %s", ErrProxy, err)
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+	case resp.StatusCode == http.StatusBadGateway, resp.StatusCode == http.StatusServiceUnavailable:
+		return conn, fmt.Errorf("http proxy: %s: %w", resp.Status, syscall.ECONNREFUSED)
+	case resp.StatusCode == http.StatusGatewayTimeout:
+		return conn, fmt.Errorf("http proxy: %s: %w", resp.Status, os.ErrDeadlineExceeded)
+	default:
+		return conn, fmt.Errorf("%w: http proxy: %s", ErrProxy, resp.Status)
+	}
+
+	// The service may have talked already, its banner must not be lost in the reader's buffer
+	if br.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, r: br}, nil
+	}
+	return conn, nil
+}
+
+// bufferedConn is a connection whose first bytes were already read into r
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
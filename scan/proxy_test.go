@@ -0,0 +1,235 @@
+package scan_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/nguyenanhhao221/pScan/scan"
+)
+
+// proxyStandIn is the part of an in-process proxy that speaks its protocol. handshake
+// reads the request of a client and returns the address it asks for, or "" to drop
+// the client, reply tells the client whether the proxy could reach that address
+type proxyStandIn struct {
+	handshake func(conn net.Conn, r *bufio.Reader) string
+	reply     func(conn net.Conn, ok bool)
+}
+
+// serveProxy runs the stand-in on a local port and returns its address
+func serveProxy(t *testing.T, p proxyStandIn) string {
+	t.Helper()
+
+	port := serveFunc(t, func(conn net.Conn) {
+		r := bufio.NewReader(conn)
+		address := p.handshake(conn, r)
+		if address == "" {
+			return
+		}
+		target, err := net.DialTimeout("tcp", address, time.Second)
+		p.reply(conn, err == nil)
+		if err != nil {
+			return
+		}
+		defer target.Close()
+		go io.Copy(target, r)
+		io.Copy(conn, target)
+	})
+	return net.JoinHostPort("127.0.0.1", strconv.Itoa(port))
+}
+
+// socks5StandIn is a SOCKS5 proxy that requires user and pass when user is set
+func socks5StandIn(user, pass string) proxyStandIn {
+	handshake := func(conn net.Conn, r *bufio.Reader) string {
+		head := make([]byte, 2)
+		io.ReadFull(r, head)
+		io.ReadFull(r, make([]byte, head[1]))
+		if user == "" {
+			conn.Write([]byte{0x05, 0x00})
+		} else {
+			conn.Write([]byte{0x05, 0x02})
+			r.ReadByte()
+			u := make([]byte, readByte(r))
+			io.ReadFull(r, u)
+			p := make([]byte, readByte(r))
+			io.ReadFull(r, p)
+			if string(u) != user || string(p) != pass {
+				conn.Write([]byte{0x01, 0x01})
+				return ""
+			}
+			conn.Write([]byte{0x01, 0x00})
+		}
+
+		req := make([]byte, 4)
+		io.ReadFull(r, req)
+		var host string
+		switch req[3] {
+		case 0x01:
+			ip := make([]byte, 4)
+			io.ReadFull(r, ip)
+			host = net.IP(ip).String()
+		case 0x03:
+			name := make([]byte, readByte(r))
+			io.ReadFull(r, name)
+			host = string(name)
+		}
+		port := make([]byte, 2)
+		io.ReadFull(r, port)
+		return net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port))))
+	}
+
+	reply := func(conn net.Conn, ok bool) {
+		code := byte(0x05)
+		if ok {
+			code = 0x00
+		}
+		conn.Write([]byte{0x05, code, 0x00, 0x01, 127, 0, 0, 1, 0, 0})
+	}
+	return proxyStandIn{handshake: handshake, reply: reply}
+}
+
+func readByte(r *bufio.Reader) int {
+	b, _ := r.ReadByte()
+	return int(b)
+}
+
+// connectStandIn is an HTTP proxy that answers CONNECT with a 502 when it cannot reach the port
+func connectStandIn() proxyStandIn {
+	handshake := func(conn net.Conn, r *bufio.Reader) string {
+		req, err := http.ReadRequest(r)
+		if err != nil || req.Method != http.MethodConnect {
+			return ""
+		}
+		return req.Host
+	}
+
+	reply := func(conn net.Conn, ok bool) {
+		if ok {
+			io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\n")
+			return
+		}
+		io.WriteString(conn, "HTTP/1.1 502 Bad Gateway\r\nContent-Length: 0\r\n\r\n")
+	}
+	return proxyStandIn{handshake: handshake, reply: reply}
+}
+
+func TestProxyDialer(t *testing.T) {
+	openPort := serve(t, "SSH-2.0-Behind_Proxy\r\n")
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedPort := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+
+	testCases := []struct {
+		name string
+		url  string
+	}{
+		{name: "SOCKS5", url: "socks5://" + serveProxy(t, socks5StandIn("", ""))},
+		{name: "SOCKS5Auth", url: "socks5://scanner:secret@" + serveProxy(t, socks5StandIn("scanner", "secret"))},
+		{name: "HTTPConnect", url: "http://" + serveProxy(t, connectStandIn())},
+	}
+
+	hl := &scan.HostList{}
+	if err := hl.Add("localhost"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			d, err := scan.NewProxyDialer(tc.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			s := &scan.Scanner{Options: scan.Options{Banner: true}, Dialer: d}
+
+			res, err := s.Run(context.Background(), hl, []int{openPort, closedPort})
+			if err != nil {
+				t.Fatalf("Expect no error, got %q", err)
+			}
+			open, closed := res[0].PortStates[0], res[0].PortStates[1]
+			if open.State != scan.StateOpen || open.Banner != "SSH-2.0-Behind_Proxy" {
+				t.Errorf("Expect open port with its banner, got %+v", open)
+			}
+			if closed.State != scan.StateClosed || closed.Reason != "conn-refused" {
+				t.Errorf("Expect the proxy's failure to close the port, got %+v", closed)
+			}
+		})
+	}
+}
+
+func TestProxyResolvesHosts(t *testing.T) {
+	openPort := serve(t, "SSH-2.0-Internal\r\n")
+
+	// Only the proxy knows internal.test
+	p := socks5StandIn("", "")
+	handshake := p.handshake
+	p.handshake = func(conn net.Conn, r *bufio.Reader) string {
+		host, port, _ := net.SplitHostPort(handshake(conn, r))
+		if host != "internal.test" {
+			return ""
+		}
+		return net.JoinHostPort("127.0.0.1", port)
+	}
+	d, err := scan.NewProxyDialer("socks5://" + serveProxy(t, p))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &scan.Scanner{Dialer: d}
+	res, err := s.Run(context.Background(), &scan.HostList{Hosts: []string{"internal.test"}}, []int{openPort})
+	if err != nil {
+		t.Fatalf("Expect no error, got %q", err)
+	}
+	if len(res) != 1 || res[0].NotFound || res[0].Addr != "" {
+		t.Fatalf("Expect a single result left for the proxy to look up, got %+v", res)
+	}
+	if ps := res[0].PortStates[0]; ps.State != scan.StateOpen {
+		t.Errorf("Expect port open through the proxy, got %+v", ps)
+	}
+
+	s.IPv4Only = true
+	if _, err := s.Run(context.Background(), &scan.HostList{Hosts: []string{"internal.test"}}, []int{openPort}); !errors.Is(err, scan.ErrInvalidFamily) {
+		t.Errorf("Expect error %q, got %v", scan.ErrInvalidFamily, err)
+	}
+}
+
+func TestProxyDialerErrors(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadProxy := ln.Addr().String()
+	ln.Close()
+
+	testCases := []struct {
+		name   string
+		url    string
+		expErr error
+	}{
+		{name: "UnknownScheme", url: "ftp://127.0.0.1:21", expErr: scan.ErrInvalidProxy},
+		{name: "NoPort", url: "socks5://127.0.0.1", expErr: scan.ErrInvalidProxy},
+		{name: "ProxyDown", url: "socks5://" + deadProxy, expErr: scan.ErrProxy},
+		{name: "BadCredentials", url: "socks5://scanner:wrong@" + serveProxy(t, socks5StandIn("scanner", "secret")), expErr: scan.ErrProxy},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			d, err := scan.NewProxyDialer(tc.url)
+			if err == nil {
+				_, err = d.DialContext(context.Background(), "tcp", "127.0.0.1:22")
+			}
+			if !errors.Is(err, tc.expErr) {
+				t.Errorf("Expect error %q, got %v", tc.expErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package scan
+
+import (
+	"context"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// Clock tells the time and waits between probes when a scan is paced.
+// Tests replace it to check the pacing without sleeping
+type Clock interface {
+	Now() time.Time
+	// Sleep waits for d, it returns ctx.Err() if ctx is done first
+	Sleep(ctx context.Context, d time.Duration) error
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) Sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// pacer spaces probes out by at least interval across the scan, and by at least
+// hostInterval plus a random jitter below jitter for each host. Every call to wait
+// reserves the next slot free for both at once, so concurrent callers queue up and
+// the gap between two probes to a host is measured from when they actually start
+type pacer struct {
+	clock        Clock
+	interval     time.Duration
+	hostInterval time.Duration
+	jitter       time.Duration
+
+	mu       sync.Mutex
+	next     time.Time
+	hostNext []time.Time
+}
+
+// newPacer returns a pacer for hosts hosts, or nil when there is nothing to pace
+func newPacer(clock Clock, interval, hostInterval, jitter time.Duration, hosts int) *pacer {
+	if interval <= 0 && hostInterval <= 0 && jitter <= 0 {
+		return nil
+	}
+	return &pacer{
+		clock:        clock,
+		interval:     interval,
+		hostInterval: hostInterval,
+		jitter:       jitter,
+		hostNext:     make([]time.Time, hosts),
+	}
+}
+
+// wait blocks until the next slot for host h, a nil pacer never blocks
+func (p *pacer) wait(ctx context.Context, h int) error {
+	if p == nil {
+		return ctx.Err()
+	}
+
+	p.mu.Lock()
+	now := p.clock.Now()
+	at := now
+	if at.Before(p.next) {
+		at = p.next
+	}
+	if at.Before(p.hostNext[h]) {
+		at = p.hostNext[h]
+	}
+	p.next = at.Add(p.interval)
+	gap := p.hostInterval
+	if p.jitter > 0 {
+		gap += rand.N(p.jitter)
+	}
+	p.hostNext[h] = at.Add(gap)
+	p.mu.Unlock()
+
+	if d := at.Sub(now); d > 0 {
+		return p.clock.Sleep(ctx, d)
+	}
+	return ctx.Err()
+}
+
+// rateInterval returns the time between events happening rate times per second
+func rateInterval(rate float64) time.Duration {
+	if rate <= 0 {
+		return 0
+	}
+	return time.Duration(float64(time.Second) / rate)
+}
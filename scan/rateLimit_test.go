@@ -0,0 +1,322 @@
+package scan_test
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nguyenanhhao221/pScan/scan"
+)
+
+// fakeClock only moves forward when a probe sleeps, so pacing is checked without waiting
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Duration
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return time.Unix(0, 0).Add(c.now)
+}
+
+func (c *fakeClock) Sleep(ctx context.Context, d time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now += d
+	return ctx.Err()
+}
+
+// clockDialer refuses every connection, or times out when timeout is set,
+// recording the address and the time of each dial
+type clockDialer struct {
+	clock   *fakeClock
+	timeout bool
+	dialed  []string
+	at      []time.Duration
+}
+
+func (d *clockDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	at := d.clock.Now().Sub(time.Unix(0, 0))
+	d.dialed = append(d.dialed, fmt.Sprintf("%s@%s", address, at))
+	d.at = append(d.at, at)
+	if d.timeout {
+		return nil, &net.OpError{Op: "dial", Net: network, Err: os.ErrDeadlineExceeded}
+	}
+	return (&fakeDialer{}).DialContext(ctx, network, address)
+}
+
+func TestRunPacing(t *testing.T) {
+	hl := &scan.HostList{Hosts: []string{"127.0.0.1", "127.0.0.2"}}
+
+	testCases := []struct {
+		name    string
+		opts    scan.Options
+		timeout bool
+		exp     []string
+	}{
+		{
+			name: "NoLimit",
+			exp:  []string{"127.0.0.1:22@0s", "127.0.0.2:22@0s", "127.0.0.1:23@0s", "127.0.0.2:23@0s"},
+		},
+		{
+			name: "Rate",
+			opts: scan.Options{Rate: 10},
+			exp:  []string{"127.0.0.1:22@0s", "127.0.0.2:22@100ms", "127.0.0.1:23@200ms", "127.0.0.2:23@300ms"},
+		},
+		{
+			// Probes to different hosts are not held back by each other
+			name: "HostRate",
+			opts: scan.Options{HostRate: 4},
+			exp:  []string{"127.0.0.1:22@0s", "127.0.0.2:22@0s", "127.0.0.1:23@250ms", "127.0.0.2:23@250ms"},
+		},
+		{
+			name: "DelayOverHostRate",
+			opts: scan.Options{HostRate: 10, Delay: 500 * time.Millisecond},
+			exp:  []string{"127.0.0.1:22@0s", "127.0.0.2:22@0s", "127.0.0.1:23@500ms", "127.0.0.2:23@500ms"},
+		},
+		{
+			name: "RateAndHostRate",
+			opts: scan.Options{Rate: 10, HostRate: 4},
+			exp:  []string{"127.0.0.1:22@0s", "127.0.0.2:22@100ms", "127.0.0.1:23@250ms", "127.0.0.2:23@350ms"},
+		},
+		{
+			// Retries wait for their slot like any other probe
+			name:    "Retries",
+			opts:    scan.Options{Rate: 10, Retries: 1},
+			timeout: true,
+			exp: []string{
+				"127.0.0.1:22@0s", "127.0.0.1:22@100ms", "127.0.0.2:22@200ms", "127.0.0.2:22@300ms",
+				"127.0.0.1:23@400ms", "127.0.0.1:23@500ms", "127.0.0.2:23@600ms", "127.0.0.2:23@700ms",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			clock := &fakeClock{}
+			d := &clockDialer{clock: clock, timeout: tc.timeout}
+			// A single worker makes the order of the probes predictable
+			tc.opts.Workers = 1
+			s := &scan.Scanner{Options: tc.opts, Dialer: d, Clock: clock}
+
+			if _, err := s.Run(context.Background(), hl, []int{22, 23}); err != nil {
+				t.Fatalf("Expect no error, got %q", err)
+			}
+			if diff := cmp.Diff(tc.exp, d.dialed); diff != "" {
+				t.Errorf("Pacing mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRunJitter(t *testing.T) {
+	clock := &fakeClock{}
+	d := &clockDialer{clock: clock}
+	opts := scan.Options{Workers: 1, Delay: 100 * time.Millisecond, Jitter: 50 * time.Millisecond}
+	s := &scan.Scanner{Options: opts, Dialer: d, Clock: clock}
+
+	ports := []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	if _, err := s.Run(context.Background(), &scan.HostList{Hosts: []string{"127.0.0.1"}}, ports); err != nil {
+		t.Fatalf("Expect no error, got %q", err)
+	}
+
+	if len(d.at) != len(ports) {
+		t.Fatalf("Expect %d probes, got %d", len(ports), len(d.at))
+	}
+	for i := 1; i < len(d.at); i++ {
+		if gap := d.at[i] - d.at[i-1]; gap < opts.Delay || gap >= opts.Delay+opts.Jitter {
+			t.Errorf("Expect a gap between %s and %s before probe %d, got %s", opts.Delay, opts.Delay+opts.Jitter, i, gap)
+		}
+	}
+}
+
+// stepClock paces several workers without sleeping. Sleepers block until every worker
+// with a probe left is asleep, then the clock jumps to the earliest wake up, so each
+// probe is dialed exactly at the slot it reserved. It also refuses every dial,
+// recording the time of each dial by host
+type stepClock struct {
+	mu       sync.Mutex
+	now      time.Duration
+	workers  int
+	probes   int
+	sleepers []*sleeper
+	dialed   map[string][]time.Duration
+}
+
+type sleeper struct {
+	at   time.Duration
+	wake chan struct{}
+}
+
+func (c *stepClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return time.Unix(0, 0).Add(c.now)
+}
+
+func (c *stepClock) Sleep(ctx context.Context, d time.Duration) error {
+	c.mu.Lock()
+	s := &sleeper{at: c.now + d, wake: make(chan struct{})}
+	c.sleepers = append(c.sleepers, s)
+	c.step()
+	c.mu.Unlock()
+
+	select {
+	case <-s.wake:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *stepClock) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	c.mu.Lock()
+	host, _, _ := net.SplitHostPort(address)
+	c.dialed[host] = append(c.dialed[host], c.now)
+	c.probes--
+	c.step()
+	c.mu.Unlock()
+	return (&fakeDialer{}).DialContext(ctx, network, address)
+}
+
+// step wakes the earliest sleepers once no worker is busy
+func (c *stepClock) step() {
+	if len(c.sleepers) == 0 || len(c.sleepers) < min(c.workers, c.probes) {
+		return
+	}
+	next := c.sleepers[0].at
+	for _, s := range c.sleepers {
+		next = min(next, s.at)
+	}
+	c.now = max(c.now, next)
+
+	var asleep []*sleeper
+	for _, s := range c.sleepers {
+		if s.at <= c.now {
+			close(s.wake)
+			continue
+		}
+		asleep = append(asleep, s)
+	}
+	c.sleepers = asleep
+}
+
+func TestRunPacingWorkers(t *testing.T) {
+	hosts := []string{"127.0.0.1", "127.0.0.2", "127.0.0.3"}
+	ports := []int{1, 2, 3, 4}
+	opts := scan.Options{Workers: 3, Rate: 10, Delay: 500 * time.Millisecond}
+
+	clock := &stepClock{workers: opts.Workers, probes: len(hosts) * len(ports), dialed: map[string][]time.Duration{}}
+	s := &scan.Scanner{Options: opts, Dialer: clock, Clock: clock}
+	if _, err := s.Run(context.Background(), &scan.HostList{Hosts: hosts}, ports); err != nil {
+		t.Fatalf("Expect no error, got %q", err)
+	}
+
+	// Waiting on the global rate must not squeeze the delay between probes to a host
+	for _, host := range hosts {
+		at := clock.dialed[host]
+		if len(at) != len(ports) {
+			t.Fatalf("Expect %d probes to %s, got %d", len(ports), host, len(at))
+		}
+		for i := 1; i < len(at); i++ {
+			if gap := at[i] - at[i-1]; gap < opts.Delay {
+				t.Errorf("Expect at least %s between probes to %s, got %s in %v", opts.Delay, host, gap, at)
+			}
+		}
+	}
+}
+
+func TestRunPacingCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	clock := &cancelClock{cancel: cancel}
+	s := &scan.Scanner{Options: scan.Options{Workers: 1, Rate: 1}, Dialer: &fakeDialer{}, Clock: clock}
+	res, err := s.Run(ctx, &scan.HostList{Hosts: []string{"127.0.0.1"}}, []int{22, 23, 80})
+	if err == nil {
+		t.Fatal("Expect the scan to be canceled")
+	}
+	if ps := res[0].PortStates; ps[0].Canceled || !ps[1].Canceled || !ps[2].Canceled {
+		t.Errorf("Expect only the first port probed, got %+v", ps)
+	}
+}
+
+// cancelClock cancels the scan the first time a probe has to wait
+type cancelClock struct {
+	cancel context.CancelFunc
+}
+
+func (c *cancelClock) Now() time.Time {
+	return time.Unix(0, 0)
+}
+
+func (c *cancelClock) Sleep(ctx context.Context, d time.Duration) error {
+	c.cancel()
+	return ctx.Err()
+}
+
+// busyDialer refuses every connection after a short wait, recording the most connections in progress at once
+type busyDialer struct {
+	mu      sync.Mutex
+	active  int
+	maxSeen int
+}
+
+func (d *busyDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	d.mu.Lock()
+	d.active++
+	d.maxSeen = max(d.maxSeen, d.active)
+	d.mu.Unlock()
+
+	time.Sleep(20 * time.Millisecond)
+
+	d.mu.Lock()
+	d.active--
+	d.mu.Unlock()
+	return (&fakeDialer{}).DialContext(ctx, network, address)
+}
+
+func TestRunDualStackLimits(t *testing.T) {
+	resolver, err := scan.NewResolver(serveDNS(t, map[string][]string{"dual.test": {"127.0.0.1", "::1"}}, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hl := &scan.HostList{Hosts: []string{"dual.test"}}
+
+	// Both addresses lead to the same device, they share its limits
+	t.Run("HostWorkers", func(t *testing.T) {
+		d := &busyDialer{}
+		s := &scan.Scanner{Options: scan.Options{HostWorkers: 1}, Dialer: d, Resolver: resolver}
+		if _, err := s.Run(context.Background(), hl, []int{22, 23, 80}); err != nil {
+			t.Fatalf("Expect no error, got %q", err)
+		}
+		if d.maxSeen != 1 {
+			t.Errorf("Expect 1 probe at a time, got %d", d.maxSeen)
+		}
+	})
+
+	t.Run("HostRate", func(t *testing.T) {
+		clock := &fakeClock{}
+		d := &clockDialer{clock: clock}
+		s := &scan.Scanner{Options: scan.Options{Workers: 1, HostRate: 4}, Dialer: d, Clock: clock, Resolver: resolver}
+		if _, err := s.Run(context.Background(), hl, []int{22}); err != nil {
+			t.Fatalf("Expect no error, got %q", err)
+		}
+		// The resolver decides which address comes first, only the times matter here
+		var got []string
+		for _, dial := range d.dialed {
+			got = append(got, dial[strings.LastIndex(dial, "@")+1:])
+		}
+		exp := []string{"0s", "250ms"}
+		if diff := cmp.Diff(exp, got); diff != "" {
+			t.Errorf("Pacing mismatch (-want +got):\n%s", diff)
+		}
+	})
+}
@@ -0,0 +1,97 @@
+package scan
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"sync"
+	"time"
+)
+
+var ErrInvalidResolver = errors.New("invalid resolver")
+
+// NewResolver returns a resolver that sends every query to the DNS server at
+// address instead of the servers configured on the machine. The port defaults to 53
+func NewResolver(address string) (*net.Resolver, error) {
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(address, "53")
+	}
+	host, _, _ := net.SplitHostPort(address)
+	if _, err := netip.ParseAddr(host); err != nil {
+		return nil, fmt.Errorf("%w: %q is not an IP address", ErrInvalidResolver, host)
+	}
+
+	d := net.Dialer{Timeout: 2 * time.Second}
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return d.DialContext(ctx, network, address)
+		},
+	}, nil
+}
+
+// wildcards detects resolvers that hijack NXDOMAIN answers and reply with an address
+// for names that do not exist. It resolves a random name under the reserved .invalid
+// TLD, which no zone can answer for, and hosts whose addresses are all among the answers
+// for it are not trusted. Wildcard records of a zone are real answers and are not caught,
+// the hosts that share their address are scanned
+type wildcards struct {
+	resolver *net.Resolver
+
+	mu     sync.Mutex
+	done   bool
+	canary map[string]bool
+}
+
+func newWildcards(resolver *net.Resolver) *wildcards {
+	return &wildcards{resolver: resolver}
+}
+
+// match reports whether the addrs host resolved to are hijacked answers
+func (w *wildcards) match(ctx context.Context, host string, addrs []string) bool {
+	if _, err := netip.ParseAddr(host); err == nil || len(addrs) == 0 {
+		return false
+	}
+
+	canary := w.canaryAddrs(ctx)
+	if len(canary) == 0 {
+		return false
+	}
+	for _, a := range addrs {
+		if !canary[a] {
+			return false
+		}
+	}
+	return true
+}
+
+// canaryAddrs returns the addresses a name that cannot exist resolves to, usually none.
+// The name is looked up once, unless ctx is done first and the next caller tries again
+func (w *wildcards) canaryAddrs(ctx context.Context) map[string]bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.done {
+		return w.canary
+	}
+
+	label := make([]byte, 8)
+	rand.Read(label)
+	// The trailing dot keeps the search domains, which may have wildcards, out of the lookup
+	addrs, err := w.resolver.LookupHost(ctx, "pscan-canary-"+hex.EncodeToString(label)+".invalid.")
+	if err != nil && ctx.Err() != nil {
+		return nil
+	}
+	w.done = true
+	if err != nil {
+		return nil
+	}
+	w.canary = map[string]bool{}
+	for _, a := range addrs {
+		w.canary[a] = true
+	}
+	return w.canary
+}
@@ -0,0 +1,269 @@
+package scan_test
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"maps"
+	"net"
+	"net/netip"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nguyenanhhao221/pScan/scan"
+)
+
+// serveDNS runs a DNS stand-in on a local UDP port answering A and AAAA queries for
+// the names in records, "*.<domain>" records answer for the names in domain they do not list.
+// Other names get hijack as answer, or NXDOMAIN when it is empty
+func serveDNS(t *testing.T, records map[string][]string, hijack string) string {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if reply := dnsReply(buf[:n], records, hijack); reply != nil {
+				conn.WriteTo(reply, addr)
+			}
+		}
+	}()
+
+	return conn.LocalAddr().String()
+}
+
+// dnsReply answers a query holding a single question
+func dnsReply(query []byte, records map[string][]string, hijack string) []byte {
+	if len(query) < 12 {
+		return nil
+	}
+
+	// The question name is a sequence of length prefixed labels ending with an empty one
+	var labels []string
+	i := 12
+	for i < len(query) && query[i] != 0 {
+		l := int(query[i])
+		if i+1+l > len(query) {
+			return nil
+		}
+		labels = append(labels, string(query[i+1:i+1+l]))
+		i += 1 + l
+	}
+	end := i + 5
+	if end > len(query) {
+		return nil
+	}
+	name := strings.ToLower(strings.Join(labels, "."))
+	qtype := binary.BigEndian.Uint16(query[i+1:])
+
+	ips, ok := records[name]
+	if !ok {
+		_, parent, _ := strings.Cut(name, ".")
+		ips, ok = records["*."+parent]
+	}
+	if !ok && hijack != "" {
+		ips = []string{hijack}
+	}
+
+	// Type A queries get the IPv4 addresses, type AAAA the IPv6 ones
+	var answers []netip.Addr
+	for _, ip := range ips {
+		a := netip.MustParseAddr(ip)
+		if (qtype == 1 && a.Is4()) || (qtype == 28 && a.Is6()) {
+			answers = append(answers, a)
+		}
+	}
+
+	// Copy the ID and the question, set the response, recursion desired and available flags
+	reply := append([]byte{}, query[:2]...)
+	rcode := byte(0)
+	if len(ips) == 0 {
+		rcode = 3
+	}
+	reply = append(reply, 0x81, 0x80|rcode, 0x00, 0x01)
+	reply = binary.BigEndian.AppendUint16(reply, uint16(len(answers)))
+	reply = append(reply, 0x00, 0x00, 0x00, 0x00)
+	reply = append(reply, query[12:end]...)
+	for _, a := range answers {
+		// Name pointer to the question, type, class IN, TTL 60 and the address
+		reply = append(reply, 0xc0, 0x0c)
+		reply = binary.BigEndian.AppendUint16(reply, qtype)
+		reply = append(reply, 0x00, 0x01, 0x00, 0x00, 0x00, 0x3c)
+		reply = binary.BigEndian.AppendUint16(reply, uint16(a.BitLen()/8))
+		reply = append(reply, a.AsSlice()...)
+	}
+	return reply
+}
+
+func TestRunResolver(t *testing.T) {
+	records := map[string][]string{"web.test": {"10.0.0.1"}, "www.test": {"10.0.0.99"}}
+
+	testCases := []struct {
+		name     string
+		wildcard string
+		hijack   string
+		exp      map[string][]string
+		expFound map[string]bool
+		expWild  map[string]bool
+	}{
+		{
+			name:     "NXDOMAIN",
+			exp:      map[string][]string{"web.test": {"10.0.0.1"}, "www.test": {"10.0.0.99"}},
+			expFound: map[string]bool{"web.test": true, "www.test": true, "ghost.test": false},
+			expWild:  map[string]bool{"web.test": false, "www.test": false, "ghost.test": false},
+		},
+		{
+			// A host that resolves like a name that does not exist cannot be told apart from it
+			name:     "Hijack",
+			hijack:   "10.0.0.99",
+			exp:      map[string][]string{"web.test": {"10.0.0.1"}, "www.test": {"10.0.0.99"}, "ghost.test": {"10.0.0.99"}},
+			expFound: map[string]bool{"web.test": true, "www.test": false, "ghost.test": false},
+			expWild:  map[string]bool{"web.test": false, "www.test": true, "ghost.test": true},
+		},
+		{
+			// The zone answers for every name, hosts sharing the address of the wildcard are real
+			name:     "ZoneWildcard",
+			wildcard: "10.0.0.99",
+			exp:      map[string][]string{"web.test": {"10.0.0.1"}, "www.test": {"10.0.0.99"}, "ghost.test": {"10.0.0.99"}},
+			expFound: map[string]bool{"web.test": true, "www.test": true, "ghost.test": true},
+			expWild:  map[string]bool{"web.test": false, "www.test": false, "ghost.test": false},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			zone := maps.Clone(records)
+			if tc.wildcard != "" {
+				zone["*.test"] = []string{tc.wildcard}
+			}
+			resolver, err := scan.NewResolver(serveDNS(t, zone, tc.hijack))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			hl := &scan.HostList{Hosts: []string{"web.test", "www.test", "ghost.test"}}
+			s := &scan.Scanner{Resolver: resolver}
+			res, err := s.Run(context.Background(), hl, nil)
+			if err != nil {
+				t.Fatalf("Expect no error, got %q", err)
+			}
+
+			addrs := map[string][]string{}
+			found := map[string]bool{}
+			wild := map[string]bool{}
+			for _, r := range res {
+				if r.Addrs != nil {
+					addrs[r.Host] = r.Addrs
+				}
+				found[r.Host] = !r.NotFound
+				wild[r.Host] = r.Wildcard
+			}
+			if diff := cmp.Diff(tc.exp, addrs); diff != "" {
+				t.Errorf("Addresses mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.expFound, found); diff != "" {
+				t.Errorf("Found mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.expWild, wild); diff != "" {
+				t.Errorf("Wildcard mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestNewResolver(t *testing.T) {
+	for _, address := range []string{"1.1.1.1", "1.1.1.1:53", "[2606:4700:4700::1111]:53"} {
+		if _, err := scan.NewResolver(address); err != nil {
+			t.Errorf("Expect %q to be valid, got %q", address, err)
+		}
+	}
+	if _, err := scan.NewResolver("dns.example.com"); !errors.Is(err, scan.ErrInvalidResolver) {
+		t.Errorf("Expect error %q, got %v", scan.ErrInvalidResolver, err)
+	}
+}
+
+func TestRunAddresses(t *testing.T) {
+	port := serve(t, "")
+	records := map[string][]string{
+		"dual.test": {"127.0.0.1", "127.0.0.2", "::1"},
+		"v4.test":   {"127.0.0.1"},
+	}
+	resolver, err := scan.NewResolver(serveDNS(t, records, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name     string
+		opts     scan.Options
+		exp      []string
+		expFound map[string]bool
+	}{
+		{
+			name:     "AllAddresses",
+			exp:      []string{"dual.test (127.0.0.1)", "dual.test (127.0.0.2)", "dual.test (::1)", "v4.test"},
+			expFound: map[string]bool{"dual.test": true, "v4.test": true},
+		},
+		{
+			name:     "IPv4Only",
+			opts:     scan.Options{IPv4Only: true},
+			exp:      []string{"dual.test (127.0.0.1)", "dual.test (127.0.0.2)", "v4.test"},
+			expFound: map[string]bool{"dual.test": true, "v4.test": true},
+		},
+		{
+			name:     "IPv6Only",
+			opts:     scan.Options{IPv6Only: true},
+			exp:      []string{"dual.test (::1)", "v4.test"},
+			expFound: map[string]bool{"dual.test": true, "v4.test": false},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			hl := &scan.HostList{Hosts: []string{"dual.test", "v4.test"}}
+			s := &scan.Scanner{Options: tc.opts, Resolver: resolver}
+			res, err := s.Run(context.Background(), hl, []int{port})
+			if err != nil {
+				t.Fatalf("Expect no error, got %q", err)
+			}
+
+			var names []string
+			found := map[string]bool{}
+			for _, r := range res {
+				names = append(names, r.Name())
+				found[r.Host] = !r.NotFound
+				// Only the loopback address the test server listens on has the port open
+				if r.Addr == "127.0.0.1" && r.PortStates[0].State != scan.StateOpen {
+					t.Errorf("Expect port open on %s, got %s", r.Name(), r.PortStates[0].State)
+				}
+				if r.Addr == "127.0.0.2" && r.PortStates[0].State == scan.StateOpen {
+					t.Errorf("Expect port not open on %s", r.Name())
+				}
+			}
+			// The resolver orders the addresses of a host by its own preferences
+			slices.Sort(names)
+			if diff := cmp.Diff(tc.exp, names); diff != "" {
+				t.Errorf("Results mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.expFound, found); diff != "" {
+				t.Errorf("Found mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	s := &scan.Scanner{Options: scan.Options{IPv4Only: true, IPv6Only: true}}
+	if _, err := s.Run(context.Background(), &scan.HostList{}, nil); !errors.Is(err, scan.ErrInvalidFamily) {
+		t.Errorf("Expect error %q, got %v", scan.ErrInvalidFamily, err)
+	}
+}
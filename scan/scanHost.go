@@ -1,69 +1,379 @@
 package scan
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net"
+	"sync"
+	"syscall"
 	"time"
 )
 
-type state bool
+var (
+	ErrInvalidState    = errors.New("invalid port state")
+	ErrInvalidProtocol = errors.New("invalid protocol")
+	ErrInvalidFamily   = errors.New("invalid address family")
+)
+
+// Protocols that ports can be scanned with
+const (
+	ProtocolTCP = "tcp"
+	ProtocolUDP = "udp"
+)
+
+// State represents the state of a scanned port
+type State int
 
-func (s state) String() string {
-	if s {
+const (
+	// StateClosed means the host actively refused the connection
+	StateClosed State = iota
+	// StateOpen means the connection was accepted
+	StateOpen
+	// StateFiltered means no answer came back or the host or network was reported unreachable,
+	// usually because a firewall drops the packets
+	StateFiltered
+	// StateError means the probe failed for a reason that says nothing about the port
+	StateError
+	// StateOpenFiltered means a UDP probe got no reply, either because the service
+	// ignored it or because a firewall dropped it
+	StateOpenFiltered
+)
+
+func (s State) String() string {
+	switch s {
+	case StateOpen:
 		return "open"
+	case StateClosed:
+		return "closed"
+	case StateFiltered:
+		return "filtered"
+	case StateError:
+		return "error"
+	case StateOpenFiltered:
+		return "open|filtered"
+	}
+	return fmt.Sprintf("State(%d)", int(s))
+}
+
+// ParseState returns the state whose String method returns s
+func ParseState(s string) (State, error) {
+	for _, st := range []State{StateOpen, StateClosed, StateFiltered, StateError, StateOpenFiltered} {
+		if st.String() == s {
+			return st, nil
+		}
 	}
-	return "closed"
+	return 0, fmt.Errorf("%w: %q", ErrInvalidState, s)
 }
 
 // PortState represent the scan for a single port
 type PortState struct {
 	Port int
-	Open state
+	// Protocol is the protocol the port was scanned with, ProtocolTCP or ProtocolUDP
+	Protocol string
+	State    State
+	// Reason explains why the port got its state, e.g. "conn-refused" or "no-response"
+	Reason string
+	// Latency is how long the host took to accept or refuse the connection, or to answer
+	// a UDP probe. It is zero when no answer came back
+	Latency time.Duration
+	// Banner is the start of what the service sent once connected, only grabbed with Options.Banner
+	Banner string
+	// Service and Version identify what is listening on an open port, only detected with Options.Service
+	Service string
+	Version string
+	// TLS describes the certificate of an open port that speaks TLS, only inspected with Options.TLS
+	TLS *TLSInfo
+	// HTTP describes what an open HTTP or HTTPS port serves, only probed with Options.HTTP
+	HTTP *HTTPInfo
+	// Canceled is set when the scan stopped before this port could be probed
+	Canceled bool
 }
 
 // Results represents the scan results for a single host
 type Results struct {
-	Host       string
-	NotFound   bool
+	Host string
+	// Target is the host list entry Host was expanded from, it is the same as
+	// Host unless the entry is a CIDR block or an address range
+	Target   string
+	NotFound bool
+	// Wildcard is set along with NotFound when Host only resolved to the addresses
+	// the resolver gives for names that do not exist, in place of an NXDOMAIN answer
+	Wildcard bool
+	// Addrs holds the addresses Host resolved to
+	Addrs []string
+	// Addr is the address of Addrs the ports were probed on, each address gets its own results
+	Addr       string
 	PortStates []PortState
 }
 
-// Run perform a port scan on a hosts list
+// LatencySummary sums up the latency of the ports of a host that got an answer
+type LatencySummary struct {
+	Min time.Duration
+	Avg time.Duration
+	Max time.Duration
+	// Ports is the number of ports the summary is made of, the others are zero when it is
+	Ports int
+}
+
+// Latency returns the lowest, average and highest latency of the ports that got an answer
+func (r Results) Latency() LatencySummary {
+	var sum LatencySummary
+	var total time.Duration
+	for _, p := range r.PortStates {
+		if p.Latency <= 0 {
+			continue
+		}
+		if sum.Ports == 0 || p.Latency < sum.Min {
+			sum.Min = p.Latency
+		}
+		sum.Max = max(sum.Max, p.Latency)
+		total += p.Latency
+		sum.Ports++
+	}
+	if sum.Ports > 0 {
+		sum.Avg = total / time.Duration(sum.Ports)
+	}
+	return sum
+}
+
+// Name returns the host, followed by the address the ports were probed on
+// when the host resolved to more than one address
+func (r Results) Name() string {
+	if len(r.Addrs) > 1 && r.Addr != "" {
+		return fmt.Sprintf("%s (%s)", r.Host, r.Addr)
+	}
+	return r.Host
+}
+
+// DefaultWorkers is the number of concurrent probes used when Options.Workers is not set
+const DefaultWorkers = 100
+
+// Options configures how a scan is performed
+type Options struct {
+	// Protocol is the protocol ports are scanned with, ProtocolTCP is used when it is empty
+	Protocol string
+	// Workers limits the total number of probes running at the same time
+	Workers int
+	// HostWorkers limits the number of probes running at the same time against a single host,
+	// all its addresses included. Zero means the host is only limited by Workers
+	HostWorkers int
+	// IPv4Only and IPv6Only restrict the scan to the addresses of one family, hosts with
+	// no address in that family are not found. They cannot both be set
+	IPv4Only bool
+	IPv6Only bool
+	// Rate limits the number of probes started per second across the whole scan,
+	// HostRate the number started per second against a single host, all its addresses
+	// included. Zero means no limit
+	Rate     float64
+	HostRate float64
+	// Delay is the least time between two probes to the same host, each gap gets
+	// a random extra below Jitter so the probes do not come at a steady beat
+	Delay  time.Duration
+	Jitter time.Duration
+	// Timeout is how long a connection attempt or a UDP reply may take. When it is zero the timeout
+	// adapts to the round-trip time measured for each address, starting from DefaultTimeout,
+	// or is left to the ProxyDialer when probes go through a proxy
+	Timeout time.Duration
+	// Retries is the number of times a probe that got no answer is tried again
+	// before the port is reported filtered, retries count against Rate, HostRate and Delay
+	Retries int
+	// MaxExpand limits how many addresses a single CIDR block or address range
+	// in the host list may expand to, DefaultMaxExpand is used when it is zero
+	MaxExpand int
+	// Banner reads what open ports send right after the connection is made
+	Banner bool
+	// Service sends probes to open ports to detect the service listening on them
+	Service bool
+	// Probes are used to detect services, DefaultProbes is used when it is nil
+	Probes []Probe
+	// TLS makes a TLS handshake with open ports to inspect their certificate
+	TLS bool
+	// TLSExpiryDays is how many days before its expiry a certificate gets a warning,
+	// DefaultTLSExpiryDays is used when it is zero
+	TLSExpiryDays int
+	// HTTP requests / from open ports that serve HTTP or HTTPS. It turns on service detection
+	// since that is how HTTP ports are found
+	HTTP bool
+	// HTTPTimeout limits each HTTP probe, DefaultHTTPTimeout is used when it is zero
+	HTTPTimeout time.Duration
+	// BannerTimeout is how long to wait for a banner or for the reply to a service probe,
+	// DefaultBannerTimeout is used when it is zero
+	BannerTimeout time.Duration
+	// BannerSize is the most bytes read from a banner or from the reply to a service probe,
+	// DefaultBannerSize is used when it is zero
+	BannerSize int
+}
+
+// Run perform a port scan on a hosts list using the default options
 func Run(hl *HostList, ports []int) []Results {
-	res := make([]Results, 0, len(hl.Hosts))
-	for _, host := range hl.Hosts {
-		r := Results{
-			Host: host,
+	return RunOptions(hl, ports, Options{})
+}
+
+// RunOptions perform a concurrent port scan on a hosts list.
+// CIDR blocks and address ranges in the list are expanded to one result per address,
+// and so are host names that resolve to several addresses.
+// Results are returned in the same order as the hosts in the list and
+// each host's port states are in the same order as ports, or as its own ports
+// from the host list, regardless of the order in which the probes complete.
+// An entry that cannot be expanded or has invalid ports of its own gets a single
+// NotFound result with no port states and the rest of the list is still scanned.
+// Options that keep the whole scan from starting, such as an invalid protocol,
+// return no results. Use RunContext or Scanner.Run to get the errors
+func RunOptions(hl *HostList, ports []int, opts Options) []Results {
+	valid := &HostList{Info: map[string]HostInfo{}}
+	invalid := map[string]bool{}
+	for _, entry := range hl.Hosts {
+		if checkEntry(hl, entry, opts.MaxExpand) != nil {
+			invalid[entry] = true
+			continue
 		}
-		// Perform DNS lookup to see if the host exists
-		// NOTE: this function is different on machine depends on the DNS and Internet Service prodiver. In my case, I use Vietnam Viettel Internet and default DNS set up on MacOS.
-		// When given a host, this LookupHost go to the machine DNS settings, it as for an IP address from the DNS server, due to the way Viettel DNS server behave, when an invalid host is not found,
-		// It does return an error to us, instead, it return an IP Address, which make our function thought that it actually found the host.
-		// We can change this by updating our network DNS to use other DNS server such as Google or Cloudflare
-		if _, err := net.LookupHost(host); err != nil {
-			r.NotFound = true
-			res = append(res, r)
+		valid.Hosts = append(valid.Hosts, entry)
+		if info, ok := hl.Info[entry]; ok {
+			valid.Info[entry] = info
+		}
+	}
+	scanned, err := RunContext(context.Background(), valid, ports, opts)
+	if err != nil {
+		return nil
+	}
+
+	// Results of an entry follow each other, in the order of the list
+	var res []Results
+	for _, entry := range hl.Hosts {
+		if invalid[entry] {
+			res = append(res, Results{Host: entry, Target: entry, NotFound: true})
 			continue
 		}
-		// Scan the provided ports if the host is found
-		for _, port := range ports {
-			r.PortStates = append(r.PortStates, scanPort(host, port))
+		for len(scanned) > 0 && scanned[0].Target == entry {
+			res, scanned = append(res, scanned[0]), scanned[1:]
 		}
-		res = append(res, r)
 	}
 	return res
 }
 
-// scanPort perform TCP scan on a single port and host
-func scanPort(host string, port int) PortState {
-	p := PortState{Port: port}
-	address := net.JoinHostPort(host, fmt.Sprintf("%d", port))
-	scanConn, err := net.DialTimeout("tcp", address, 1*time.Second)
+// checkEntry returns why entry of hl cannot be scanned, nil when it can
+func checkEntry(hl *HostList, entry string, maxExpand int) error {
+	if _, err := ExpandTarget(entry, maxExpand); err != nil {
+		return err
+	}
+	if ports := hl.Info[entry].Ports; ports != "" {
+		if _, err := ParsePorts(ports); err != nil {
+			return fmt.Errorf("ports of %s: %w", entry, err)
+		}
+	}
+	return nil
+}
+
+// RunContext is like RunOptions but stops probing when ctx is done.
+// It always returns the results gathered so far, ports that were not probed
+// are marked as Canceled, along with ctx.Err() if the scan did not complete.
+// Entries of the host list that cannot be expanded fail the scan before any probe is sent.
+// It is a shortcut for the Run method of a Scanner with the default Dialer.
+func RunContext(ctx context.Context, hl *HostList, ports []int, opts Options) ([]Results, error) {
+	s := &Scanner{Options: opts}
+	return s.Run(ctx, hl, ports)
+}
+
+// parallel calls fn for every index in [0, n) using at most workers goroutines.
+// It stops handing out indexes once ctx is done
+func parallel(ctx context.Context, n, workers int, fn func(i int)) {
+	if n == 0 {
+		return
+	}
+	workers = min(workers, n)
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for range workers {
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				fn(i)
+			}
+		}()
+	}
+
+feed:
+	for i := range n {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// scanPort perform TCP scan on a single port and host, dialing addr when it is set.
+// Retries wait on pace
+func (s *Scanner) scanPort(ctx context.Context, host, addr string, port int, rtt *rttEstimator, pace func(context.Context) error) PortState {
+	p := PortState{Port: port, Protocol: ProtocolTCP}
+	address := dialAddress(host, addr, port)
+	scanConn, latency, err := s.connect(ctx, ProtocolTCP, address, rtt, pace)
+	p.Latency = latency
 	if err != nil {
+		if ctx.Err() != nil {
+			p.Canceled = true
+			return p
+		}
+		p.State, p.Reason = classify(err)
 		return p
 	}
 
-	scanConn.Close()
-	p.Open = true
+	defer scanConn.Close()
+	p.State, p.Reason = StateOpen, "syn-ack"
+
+	// Services that wait for the client to talk first, like HTTP, time out and have no banner
+	detect := s.Service || s.HTTP
+	var banner []byte
+	if s.Banner || detect {
+		done := endOfLine
+		if detect {
+			done = func(b []byte) bool { return endOfLine(b) || matchesNullProbe(s.Probes, b) }
+		}
+		banner = readResponse(ctx, scanConn, s.BannerTimeout, s.BannerSize, done)
+	}
+	if s.Banner {
+		p.Banner = printable(banner)
+	}
+	if detect {
+		p.Service, p.Version = s.detectService(ctx, address, port, banner)
+	}
+
+	// A service that talked first does not speak TLS. HTTPS servers often answer
+	// the plain HTTP probe with an error, so they are detected as http
+	maybeTLS := len(banner) == 0 && (p.Service == "" || p.Service == "http")
+	if s.TLS && maybeTLS {
+		p.TLS = s.inspectTLS(ctx, host, addr, port)
+	}
+	if s.HTTP && maybeTLS {
+		if !s.TLS || p.TLS != nil {
+			if p.HTTP = s.probeHTTP(ctx, "https", host, addr, port); p.HTTP != nil {
+				p.Service, p.Version = "https", p.HTTP.Server
+			}
+		}
+		if p.HTTP == nil && p.Service == "http" {
+			p.HTTP = s.probeHTTP(ctx, "http", host, addr, port)
+		}
+	}
 	return p
 }
+
+// classify maps a dial error to a port state and the reason for it
+func classify(err error) (State, string) {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return StateClosed, "conn-refused"
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return StateFiltered, "no-response"
+	case errors.Is(err, syscall.EHOSTUNREACH):
+		return StateFiltered, "host-unreach"
+	case errors.Is(err, syscall.ENETUNREACH):
+		return StateFiltered, "net-unreach"
+	}
+	return StateError, err.Error()
+}
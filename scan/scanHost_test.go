@@ -1,34 +1,51 @@
 package scan_test
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net"
 	"strconv"
 	"testing"
+	"time"
 
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/nguyenanhhao221/pScan/scan"
 )
 
 func TestStateString(t *testing.T) {
-	ps := scan.PortState{}
-
-	if ps.Open.String() != "closed" {
-		t.Errorf("Expect %q, got %q\n", "closed", ps.Open.String())
+	testCases := []struct {
+		state scan.State
+		exp   string
+	}{
+		{scan.StateOpen, "open"},
+		{scan.StateClosed, "closed"},
+		{scan.StateFiltered, "filtered"},
+		{scan.StateError, "error"},
+		{scan.StateOpenFiltered, "open|filtered"},
 	}
 
-	ps.Open = true
+	ps := scan.PortState{}
+	if ps.State.String() != "closed" {
+		t.Errorf("Expect zero value to be %q, got %q\n", "closed", ps.State.String())
+	}
 
-	if ps.Open.String() != "open" {
-		t.Errorf("Expect %q, got %q\n", "closed", ps.Open.String())
+	for _, tc := range testCases {
+		if tc.state.String() != tc.exp {
+			t.Errorf("Expect %q, got %q\n", tc.exp, tc.state.String())
+		}
 	}
 }
 
 func TestRunHostFound(t *testing.T) {
 	testCases := []struct {
-		name        string
-		expectState string
+		name         string
+		expectState  string
+		expectReason string
 	}{
-		{"OpenPort", "open"},
-		{"ClosePort", "closed"},
+		{"OpenPort", "open", "syn-ack"},
+		{"ClosePort", "closed", "conn-refused"},
 	}
 	hl := &scan.HostList{}
 	host := "localhost"
@@ -84,9 +101,12 @@ func TestRunHostFound(t *testing.T) {
 		if res[0].PortStates[i].Port != ports[i] {
 			t.Errorf("Expect %q, got %q\n", ports[i], res[0].PortStates[i].Port)
 		}
-		if res[0].PortStates[i].Open.String() != tc.expectState {
+		if res[0].PortStates[i].State.String() != tc.expectState {
 			t.Errorf("Expect port %d, to be %s\n", ports[i], tc.expectState)
 		}
+		if res[0].PortStates[i].Reason != tc.expectReason {
+			t.Errorf("Expect port %d reason %q, got %q\n", ports[i], tc.expectReason, res[0].PortStates[i].Reason)
+		}
 	}
 }
 
@@ -118,3 +138,184 @@ func TestRunHostNotFound(t *testing.T) {
 		t.Fatalf("Expected 0 port state, got %d instead\n", len(res[0].PortStates))
 	}
 }
+
+func TestRunOptionsOrder(t *testing.T) {
+	hl := &scan.HostList{}
+	for _, host := range []string{"localhost", "foo.invalid.uiweyhriweu", "127.0.0.1"} {
+		if err := hl.Add(host); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ports := []int{}
+	for i := 0; i < 6; i++ {
+		ln, err := net.Listen("tcp", net.JoinHostPort("localhost", "0"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer ln.Close()
+
+		_, portStr, err := net.SplitHostPort(ln.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		port, err := strconv.Atoi(portStr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ports = append(ports, port)
+
+		// Close every other port
+		if i%2 == 1 {
+			ln.Close()
+		}
+	}
+
+	exp := scan.RunOptions(hl, ports, scan.Options{Workers: 1})
+
+	testCases := []struct {
+		name string
+		opts scan.Options
+	}{
+		{"ManyWorkers", scan.Options{Workers: 50}},
+		{"HostLimit", scan.Options{Workers: 50, HostWorkers: 1}},
+		{"Default", scan.Options{}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			res := scan.RunOptions(hl, ports, tc.opts)
+			// Latencies are measured, they differ from one run to the next
+			if diff := cmp.Diff(exp, res, cmpopts.IgnoreFields(scan.PortState{}, "Latency")); diff != "" {
+				t.Errorf("%s mismatch (-want +got):\n%s", t.Name(), diff)
+			}
+		})
+	}
+
+	for i, r := range exp {
+		if r.Host != hl.Hosts[i] {
+			t.Errorf("Expect host %q at position %d, got %q\n", hl.Hosts[i], i, r.Host)
+		}
+		for j, p := range r.PortStates {
+			if p.Port != ports[j] {
+				t.Errorf("Expect port %d at position %d, got %d\n", ports[j], j, p.Port)
+			}
+			if p.Latency <= 0 {
+				t.Errorf("Expect the latency of %s:%d measured, got %s\n", r.Host, p.Port, p.Latency)
+			}
+		}
+	}
+}
+
+func TestRunContextCanceled(t *testing.T) {
+	hl := &scan.HostList{}
+	if err := hl.Add("localhost"); err != nil {
+		t.Fatal(err)
+	}
+	ports := []int{22, 80, 443}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	res, err := scan.RunContext(ctx, hl, ports, scan.Options{})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expect error %q, got %q instead\n", context.Canceled, err)
+	}
+
+	if len(res) != 1 {
+		t.Fatalf("Expected 1 result, got %d instead\n", len(res))
+	}
+
+	if res[0].NotFound {
+		t.Errorf("Expected host %q not to be marked as not found\n", res[0].Host)
+	}
+
+	if len(res[0].PortStates) != len(ports) {
+		t.Fatalf("Expected %d port states, got %d instead\n", len(ports), len(res[0].PortStates))
+	}
+
+	for i, p := range res[0].PortStates {
+		if p.Port != ports[i] {
+			t.Errorf("Expect port %d, got %d\n", ports[i], p.Port)
+		}
+		if !p.Canceled {
+			t.Errorf("Expect port %d to be canceled\n", p.Port)
+		}
+	}
+}
+
+func TestRunHostPorts(t *testing.T) {
+	open := serve(t, "")
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+
+	hl := &scan.HostList{}
+	for _, host := range []string{"localhost", "127.0.0.1"} {
+		if err := hl.Add(host); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// localhost keeps the ports of the scan, 127.0.0.1 gets its own
+	info := scan.HostInfo{Ports: fmt.Sprintf("%d,%d", closed, open)}
+	if err := hl.SetInfo("127.0.0.1", info); err != nil {
+		t.Fatal(err)
+	}
+
+	res := scan.RunOptions(hl, []int{open}, scan.Options{})
+
+	got := map[string][]int{}
+	for _, r := range res {
+		for _, p := range r.PortStates {
+			got[r.Host] = append(got[r.Host], p.Port)
+		}
+	}
+	exp := map[string][]int{"localhost": {open}, "127.0.0.1": {min(open, closed), max(open, closed)}}
+	if diff := cmp.Diff(exp, got); diff != "" {
+		t.Errorf("%s mismatch (-want +got):\n%s", t.Name(), diff)
+	}
+}
+
+func TestRunOptionsInvalidEntries(t *testing.T) {
+	hl := &scan.HostList{
+		Hosts: []string{"10.0.0.0/33", "127.0.0.1", "10.0.0.0/8", "127.0.0.2"},
+		Info:  map[string]scan.HostInfo{"127.0.0.2": {Ports: "foo"}},
+	}
+
+	// Entries that cannot be scanned do not keep the others from being scanned
+	res := scan.RunOptions(hl, []int{}, scan.Options{})
+
+	exp := []scan.Results{
+		{Host: "10.0.0.0/33", Target: "10.0.0.0/33", NotFound: true},
+		{Host: "127.0.0.1", Target: "127.0.0.1", Addrs: []string{"127.0.0.1"}, Addr: "127.0.0.1", PortStates: []scan.PortState{}},
+		{Host: "10.0.0.0/8", Target: "10.0.0.0/8", NotFound: true},
+		{Host: "127.0.0.2", Target: "127.0.0.2", NotFound: true},
+	}
+	if diff := cmp.Diff(exp, res); diff != "" {
+		t.Errorf("%s mismatch (-want +got):\n%s", t.Name(), diff)
+	}
+
+	if res := scan.RunOptions(hl, []int{}, scan.Options{Protocol: "sctp"}); res != nil {
+		t.Errorf("Expect no results for an invalid protocol, got %v", res)
+	}
+}
+
+func TestResultsLatency(t *testing.T) {
+	res := scan.Results{PortStates: []scan.PortState{
+		{Port: 22, State: scan.StateOpen, Latency: 2 * time.Millisecond},
+		{Port: 80, State: scan.StateFiltered},
+		{Port: 443, State: scan.StateClosed, Latency: 4 * time.Millisecond},
+		{Port: 8080, State: scan.StateClosed, Latency: 6 * time.Millisecond},
+	}}
+
+	exp := scan.LatencySummary{Min: 2 * time.Millisecond, Avg: 4 * time.Millisecond, Max: 6 * time.Millisecond, Ports: 3}
+	if diff := cmp.Diff(exp, res.Latency()); diff != "" {
+		t.Errorf("%s mismatch (-want +got):\n%s", t.Name(), diff)
+	}
+	if got := (scan.Results{}).Latency(); got != (scan.LatencySummary{}) {
+		t.Errorf("Expect no latency without ports, got %+v", got)
+	}
+}
@@ -0,0 +1,348 @@
+package scan
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Dialer makes the connections a scan probes ports with. *net.Dialer implements it,
+// other implementations can route probes through a proxy, bind a source address
+// or stand in for the network in tests
+type Dialer interface {
+	DialContext(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+// defaultDialer is used by a Scanner with no Dialer. Probes bound their connection
+// attempts with a context deadline, its own timeout only caps the other connections
+var defaultDialer Dialer = &net.Dialer{Timeout: MaxTimeout}
+
+// Scanner performs port scans configured by its Options
+type Scanner struct {
+	Options
+	// Dialer makes every connection of the scan, probes and handshakes included.
+	// A net.Dialer is used when it is nil, it must give up when the context is done.
+	// The hosts are not looked up when it is a ProxyDialer, the proxy is handed
+	// their names so it can reach hosts only known on its side
+	Dialer Dialer
+	// Resolver looks up the hosts of the list, and the host names dialed by the default
+	// Dialer. net.DefaultResolver is used when it is nil
+	Resolver *net.Resolver
+	// OnPort is called with each port state as soon as its probe completes, along with
+	// the host and address it was probed on
+	OnPort func(host, addr string, p PortState)
+	// OnHost is called with the results of each host once all its ports are probed,
+	// or once the scan stops for the hosts it did not finish, i is the index of res in
+	// the results of Run. The callbacks are never called concurrently, they come in the
+	// order probes complete rather than the order of the host list, and a slow callback
+	// slows the scan down
+	OnHost func(i int, res Results)
+	// Clock paces the probes when Rate, HostRate, Delay or Jitter is set,
+	// the system clock is used when it is nil
+	Clock Clock
+	// OnProgress is called once the hosts are looked up and after every probe,
+	// it is never called concurrently with the other callbacks
+	OnProgress func(p Progress)
+}
+
+// scanJob is a validated scan waiting to be run
+type scanJob struct {
+	targets  []target
+	ports    []int
+	protocol string
+	// entryPorts holds the ports of the host list entries that have their own
+	entryPorts map[string][]int
+}
+
+// Run performs a concurrent port scan on a hosts list the way RunContext does,
+// making every connection with the Scanner's Dialer
+func (s *Scanner) Run(ctx context.Context, hl *HostList, ports []int) ([]Results, error) {
+	job, err := s.prepare(hl, ports)
+	if err != nil {
+		return nil, err
+	}
+	return s.scan(ctx, job), ctx.Err()
+}
+
+// Stream starts the scan in the background and sends the results of each host on
+// the returned channel as soon as they are complete, as OnHost gets them. The channel
+// is closed once the scan is done and must be drained, ctx.Err() then tells whether
+// the scan completed. Errors that keep the scan from starting are returned right away
+func (s *Scanner) Stream(ctx context.Context, hl *HostList, ports []int) (<-chan Results, error) {
+	job, err := s.prepare(hl, ports)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan Results)
+	stream := *s
+	stream.OnHost = func(i int, res Results) {
+		if s.OnHost != nil {
+			s.OnHost(i, res)
+		}
+		ch <- res
+	}
+	go func() {
+		defer close(ch)
+		stream.scan(ctx, job)
+	}()
+	return ch, nil
+}
+
+// prepare checks the options and expands the host list before any packet is sent
+func (s *Scanner) prepare(hl *HostList, ports []int) (*scanJob, error) {
+	protocol := s.Protocol
+	if protocol == "" {
+		protocol = ProtocolTCP
+	}
+	if protocol != ProtocolTCP && protocol != ProtocolUDP {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidProtocol, s.Protocol)
+	}
+
+	if s.IPv4Only && s.IPv6Only {
+		return nil, fmt.Errorf("%w: IPv4Only and IPv6Only are both set", ErrInvalidFamily)
+	}
+	if (s.IPv4Only || s.IPv6Only) && s.proxied() {
+		return nil, fmt.Errorf("%w: the addresses of the hosts are unknown when a proxy looks them up", ErrInvalidFamily)
+	}
+
+	targets, err := expandTargets(hl.Hosts, s.MaxExpand)
+	if err != nil {
+		return nil, err
+	}
+
+	// Hosts with their own ports in the host list are scanned on those instead
+	entryPorts := map[string][]int{}
+	for host, info := range hl.Info {
+		if info.Ports == "" {
+			continue
+		}
+		if entryPorts[host], err = ParsePorts(info.Ports); err != nil {
+			return nil, fmt.Errorf("ports of %s: %w", host, err)
+		}
+	}
+	return &scanJob{targets: targets, ports: ports, protocol: protocol, entryPorts: entryPorts}, nil
+}
+
+// scan looks up and probes the targets of job, handing the results to the callbacks as they complete
+func (s *Scanner) scan(ctx context.Context, job *scanJob) []Results {
+	workers := s.Workers
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+	probe := s.scanPort
+	if job.protocol == ProtocolUDP {
+		probe = s.scanUDPPort
+	}
+
+	// Perform DNS lookup to see if the host exists. Some resolvers, ISP ones in particular,
+	// answer with an address for names that do not exist, such answers are caught by
+	// comparing them with the answer for a name that cannot exist.
+	// A proxy looks the hosts up itself, they are dialed by name instead
+	lookups := make([]Results, len(job.targets))
+	for i, t := range job.targets {
+		lookups[i] = Results{Host: t.host, Target: t.entry}
+	}
+	if !s.proxied() {
+		resolver := s.resolver()
+		wc := newWildcards(resolver)
+		parallel(ctx, len(lookups), workers, func(i int) {
+			addrs, err := resolver.LookupHost(ctx, lookups[i].Host)
+			if err != nil {
+				if ctx.Err() == nil {
+					lookups[i].NotFound = true
+				}
+				return
+			}
+			lookups[i].Addrs = addrs
+			if wc.match(ctx, lookups[i].Host, addrs) && ctx.Err() == nil {
+				lookups[i].NotFound = true
+				lookups[i].Wildcard = true
+			}
+		})
+	}
+
+	// Every address a host resolved to gets its own result, dual-stack hosts often
+	// have different firewall rules for each family. Hosts that were not looked up,
+	// because of a proxy or because the scan was canceled, get a single result with no address
+	var res []Results
+	var hostPorts [][]int
+	// owner holds the index of the target each result was probed for
+	var owner []int
+	maxPorts := 0
+	for i, t := range job.targets {
+		r := lookups[i]
+		addrs := []string{""}
+		if r.Addrs != nil {
+			if addrs = s.filterFamily(r.Addrs); len(addrs) == 0 {
+				r.NotFound = true
+			}
+		}
+		if r.NotFound {
+			res = append(res, r)
+			hostPorts = append(hostPorts, nil)
+			owner = append(owner, i)
+			continue
+		}
+
+		p := job.ports
+		if ep, ok := job.entryPorts[t.entry]; ok {
+			p = ep
+		}
+		maxPorts = max(maxPorts, len(p))
+		for _, a := range addrs {
+			r.Addr = a
+			r.PortStates = make([]PortState, len(p))
+			for j, port := range p {
+				r.PortStates[j] = PortState{Port: port, Protocol: job.protocol, Canceled: true}
+			}
+			res = append(res, r)
+			hostPorts = append(hostPorts, p)
+			owner = append(owner, i)
+		}
+	}
+
+	// Each target gets its own semaphore so a single host never receives more than HostWorkers
+	// probes at once, the addresses of a host share it since they often lead to the same device
+	var hostSem []chan struct{}
+	if s.HostWorkers > 0 {
+		hostSem = make([]chan struct{}, len(job.targets))
+		for i := range hostSem {
+			hostSem[i] = make(chan struct{}, s.HostWorkers)
+		}
+	}
+
+	// Probes are paced across the scan by Rate, and for each host by HostRate or Delay,
+	// whichever is slower. Retries are paced like any other probe
+	clock := s.Clock
+	if clock == nil {
+		clock = realClock{}
+	}
+	pace := newPacer(clock, rateInterval(s.Rate), max(rateInterval(s.HostRate), s.Delay), s.Jitter, len(job.targets))
+
+	// Connection timeouts adapt to the round-trip time of each address
+	rtts := make([]*rttEstimator, len(res))
+	for h := range rtts {
+		rtts[h] = &rttEstimator{}
+	}
+
+	// remaining counts the ports of each result left to probe, the result is handed
+	// to OnHost when it reaches zero. Results with no port to probe are complete already
+	var mu sync.Mutex
+	remaining := make([]int, len(res))
+	progress := Progress{}
+	for h := range res {
+		remaining[h] = len(res[h].PortStates)
+		progress.Total += remaining[h]
+		if remaining[h] == 0 && s.OnHost != nil {
+			s.OnHost(h, res[h])
+		}
+	}
+	start := time.Now()
+	if s.OnProgress != nil {
+		s.OnProgress(progress)
+	}
+
+	// Jobs are ordered port first so consecutive probes are spread across the hosts
+	// instead of hammering the first host in the list. Hosts with fewer ports than
+	// the others skip the jobs past their last port
+	parallel(ctx, len(res)*maxPorts, workers, func(i int) {
+		h, p := i%len(res), i/len(res)
+		if res[h].NotFound || p >= len(hostPorts[h]) {
+			return
+		}
+		t := owner[h]
+		if hostSem != nil {
+			select {
+			case hostSem[t] <- struct{}{}:
+				defer func() { <-hostSem[t] }()
+			case <-ctx.Done():
+				return
+			}
+		}
+		wait := func(ctx context.Context) error { return pace.wait(ctx, t) }
+		if wait(ctx) != nil {
+			return
+		}
+		ps := probe(ctx, res[h].Host, res[h].Addr, hostPorts[h][p], rtts[h], wait)
+		res[h].PortStates[p] = ps
+
+		mu.Lock()
+		defer mu.Unlock()
+		if s.OnPort != nil {
+			s.OnPort(res[h].Host, res[h].Addr, ps)
+		}
+		remaining[h]--
+		if remaining[h] == 0 && s.OnHost != nil {
+			s.OnHost(h, res[h])
+		}
+		progress.Done++
+		if ps.State == StateOpen && !ps.Canceled {
+			progress.Open++
+		}
+		if s.OnProgress != nil {
+			progress.Elapsed = time.Since(start)
+			s.OnProgress(progress)
+		}
+	})
+
+	// Hosts with ports left were stopped by ctx
+	for h := range res {
+		if remaining[h] > 0 && s.OnHost != nil {
+			s.OnHost(h, res[h])
+		}
+	}
+	return res
+}
+
+// dial connects to address with the Scanner's Dialer
+func (s *Scanner) dial(ctx context.Context, network, address string) (net.Conn, error) {
+	switch {
+	case s.Dialer != nil:
+		return s.Dialer.DialContext(ctx, network, address)
+	case s.Resolver != nil:
+		d := net.Dialer{Timeout: MaxTimeout, Resolver: s.Resolver}
+		return d.DialContext(ctx, network, address)
+	}
+	return defaultDialer.DialContext(ctx, network, address)
+}
+
+// proxied reports whether the probes go through a proxy, which looks the hosts up on its side
+func (s *Scanner) proxied() bool {
+	_, ok := s.Dialer.(*ProxyDialer)
+	return ok
+}
+
+// dialAddress returns the address to dial for port, addr when the host was resolved
+func dialAddress(host, addr string, port int) string {
+	if addr == "" {
+		addr = host
+	}
+	return net.JoinHostPort(addr, strconv.Itoa(port))
+}
+
+// filterFamily keeps the addresses of the family selected by IPv4Only or IPv6Only
+func (s *Scanner) filterFamily(addrs []string) []string {
+	if !s.IPv4Only && !s.IPv6Only {
+		return addrs
+	}
+	var kept []string
+	for _, a := range addrs {
+		ip, err := netip.ParseAddr(a)
+		if err == nil && ip.Unmap().Is4() == s.IPv4Only {
+			kept = append(kept, a)
+		}
+	}
+	return kept
+}
+
+func (s *Scanner) resolver() *net.Resolver {
+	if s.Resolver == nil {
+		return net.DefaultResolver
+	}
+	return s.Resolver
+}
@@ -0,0 +1,196 @@
+package scan_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nguyenanhhao221/pScan/scan"
+)
+
+// fakeDialer stands in for the network, it answers for the ports in greetings
+// and refuses every other connection
+type fakeDialer struct {
+	greetings map[string]string
+
+	mu     sync.Mutex
+	dialed []string
+}
+
+func (d *fakeDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	d.mu.Lock()
+	d.dialed = append(d.dialed, network+" "+address)
+	d.mu.Unlock()
+
+	greeting, ok := d.greetings[address]
+	if !ok {
+		return nil, &net.OpError{Op: "dial", Net: network, Err: syscall.ECONNREFUSED}
+	}
+	client, server := net.Pipe()
+	go func() {
+		defer server.Close()
+		server.Write([]byte(greeting))
+	}()
+	return client, nil
+}
+
+func TestScannerDialer(t *testing.T) {
+	d := &fakeDialer{greetings: map[string]string{"127.0.0.1:22": "SSH-2.0-Fake_1.0\r\n"}}
+	s := &scan.Scanner{Options: scan.Options{Banner: true, Service: true}, Dialer: d}
+
+	hl := &scan.HostList{}
+	if err := hl.Add("127.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := s.Run(context.Background(), hl, []int{22, 23})
+	if err != nil {
+		t.Fatalf("Expect no error, got %q", err)
+	}
+	if len(res) != 1 || len(res[0].PortStates) != 2 {
+		t.Fatalf("Expect 1 host with 2 ports, got %v", res)
+	}
+
+	open, closed := res[0].PortStates[0], res[0].PortStates[1]
+	if open.State != scan.StateOpen || open.Banner != "SSH-2.0-Fake_1.0" || open.Service != "ssh" {
+		t.Errorf("Expect port 22 open with the fake ssh banner, got %+v", open)
+	}
+	if closed.State != scan.StateClosed || closed.Reason != "conn-refused" {
+		t.Errorf("Expect port 23 closed by the fake dialer, got %+v", closed)
+	}
+
+	if !slices.Contains(d.dialed, "tcp 127.0.0.1:22") || !slices.Contains(d.dialed, "tcp 127.0.0.1:23") {
+		t.Errorf("Expect both ports dialed through the fake dialer, got %v", d.dialed)
+	}
+}
+
+func TestScannerCallbacks(t *testing.T) {
+	d := &fakeDialer{greetings: map[string]string{"127.0.0.1:22": "SSH-2.0-Fake_1.0\r\n"}}
+	hl := &scan.HostList{Hosts: []string{"127.0.0.1", "127.0.0.2"}}
+
+	var ports []string
+	hosts := map[int]scan.Results{}
+	s := &scan.Scanner{
+		Dialer: d,
+		OnPort: func(host, _ string, p scan.PortState) {
+			ports = append(ports, fmt.Sprintf("%s:%d %s", host, p.Port, p.State))
+		},
+		OnHost: func(i int, res scan.Results) {
+			if _, ok := hosts[i]; ok {
+				t.Errorf("Expect %s to be handed over once", res.Host)
+			}
+			hosts[i] = res
+		},
+	}
+
+	res, err := s.Run(context.Background(), hl, []int{22, 23})
+	if err != nil {
+		t.Fatalf("Expect no error, got %q", err)
+	}
+
+	slices.Sort(ports)
+	exp := []string{"127.0.0.1:22 open", "127.0.0.1:23 closed", "127.0.0.2:22 closed", "127.0.0.2:23 closed"}
+	if diff := cmp.Diff(exp, ports); diff != "" {
+		t.Errorf("Ports mismatch (-want +got):\n%s", diff)
+	}
+	for i, r := range res {
+		if diff := cmp.Diff(r, hosts[i]); diff != "" {
+			t.Errorf("Host %d mismatch (-want +got):\n%s", i, diff)
+		}
+	}
+}
+
+func TestScannerStream(t *testing.T) {
+	hl := &scan.HostList{Hosts: []string{"127.0.0.1", "127.0.0.2", "127.0.0.3"}}
+
+	t.Run("Complete", func(t *testing.T) {
+		s := &scan.Scanner{Dialer: &fakeDialer{}}
+		ch, err := s.Stream(context.Background(), hl, []int{22, 23})
+		if err != nil {
+			t.Fatalf("Expect no error, got %q", err)
+		}
+
+		var hosts []string
+		for res := range ch {
+			hosts = append(hosts, res.Host)
+			for _, p := range res.PortStates {
+				if p.Canceled || p.State != scan.StateClosed {
+					t.Errorf("Expect %s:%d probed and closed, got %+v", res.Host, p.Port, p)
+				}
+			}
+		}
+		slices.Sort(hosts)
+		if diff := cmp.Diff(hl.Hosts, hosts); diff != "" {
+			t.Errorf("Hosts mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("Canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		s := &scan.Scanner{Dialer: &fakeDialer{}}
+		ch, err := s.Stream(ctx, hl, []int{22, 23})
+		if err != nil {
+			t.Fatalf("Expect no error, got %q", err)
+		}
+		n := 0
+		for range ch {
+			n++
+		}
+		if n != len(hl.Hosts) {
+			t.Errorf("Expect every host handed over once the scan stops, got %d", n)
+		}
+	})
+
+	t.Run("InvalidProtocol", func(t *testing.T) {
+		s := &scan.Scanner{Options: scan.Options{Protocol: "sctp"}}
+		if _, err := s.Stream(context.Background(), hl, []int{22}); !errors.Is(err, scan.ErrInvalidProtocol) {
+			t.Errorf("Expect error %q, got %v", scan.ErrInvalidProtocol, err)
+		}
+	})
+}
+
+func TestScannerProgress(t *testing.T) {
+	d := &fakeDialer{greetings: map[string]string{"127.0.0.1:22": "", "127.0.0.2:22": ""}}
+	hl := &scan.HostList{Hosts: []string{"127.0.0.1", "127.0.0.2"}}
+
+	var events []scan.Progress
+	s := &scan.Scanner{Dialer: d, OnProgress: func(p scan.Progress) { events = append(events, p) }}
+	if _, err := s.Run(context.Background(), hl, []int{22, 23, 80}); err != nil {
+		t.Fatalf("Expect no error, got %q", err)
+	}
+
+	// One event once the hosts are looked up, then one per probe
+	if len(events) != 7 {
+		t.Fatalf("Expect 7 progress events, got %d", len(events))
+	}
+	for i, p := range events {
+		if p.Done != i || p.Total != 6 {
+			t.Errorf("Expect event %d to be %d/6 done, got %d/%d", i, i, p.Done, p.Total)
+		}
+	}
+	if last := events[len(events)-1]; last.Open != 2 || last.ETA() != 0 {
+		t.Errorf("Expect 2 open ports and nothing left, got %+v", last)
+	}
+}
+
+func TestProgressETA(t *testing.T) {
+	p := scan.Progress{Done: 50, Total: 200, Elapsed: 5 * time.Second}
+	if p.Rate() != 10 {
+		t.Errorf("Expect 10 probes per second, got %v", p.Rate())
+	}
+	if p.ETA() != 15*time.Second {
+		t.Errorf("Expect 15s left, got %s", p.ETA())
+	}
+	if eta := (scan.Progress{Total: 200}).ETA(); eta != 0 {
+		t.Errorf("Expect no estimate before the first probe, got %s", eta)
+	}
+}
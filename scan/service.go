@@ -0,0 +1,317 @@
+package scan
+
+import (
+	"bufio"
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+//go:embed serviceProbes.txt
+var serviceProbes string
+
+var ErrInvalidProbe = errors.New("invalid service probe")
+
+// Probe is a payload sent to an open port to find out which service is listening
+type Probe struct {
+	Name string
+	// Payload is sent once connected, an empty payload only reads what the service sends on its own
+	Payload []byte
+	// Ports are tried with this probe before any other probe that does not list them
+	Ports   []int
+	Matches []Match
+}
+
+// Match recognises a service from its reply to a probe
+type Match struct {
+	Service string
+	Pattern *regexp.Regexp
+	// Version is a template for the version of the service, $1 to $9 refer to the submatches of Pattern
+	Version string
+}
+
+var defaultProbes = sync.OnceValues(func() ([]Probe, error) {
+	return ParseProbes(strings.NewReader(serviceProbes))
+})
+
+// DefaultProbes returns the probes bundled with pScan
+func DefaultProbes() []Probe {
+	probes, err := defaultProbes()
+	if err != nil {
+		panic(fmt.Sprintf("bundled service probes: %s", err))
+	}
+	return probes
+}
+
+// ParseProbes reads probes written in the format of the bundled serviceProbes.txt file,
+// a simplified form of nmap-service-probes
+func ParseProbes(r io.Reader) ([]Probe, error) {
+	var probes []Probe
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		directive, rest, _ := strings.Cut(text, " ")
+		rest = strings.TrimSpace(rest)
+		if directive != "Probe" && len(probes) == 0 {
+			return nil, fmt.Errorf("%w: line %d: %s before the first Probe", ErrInvalidProbe, line, directive)
+		}
+
+		switch directive {
+		case "Probe":
+			p, err := parseProbeLine(rest)
+			if err != nil {
+				return nil, fmt.Errorf("%w: line %d: %s", ErrInvalidProbe, line, err)
+			}
+			probes = append(probes, p)
+		case "ports":
+			ports, err := ParsePorts(rest)
+			if err != nil {
+				return nil, fmt.Errorf("%w: line %d: %s", ErrInvalidProbe, line, err)
+			}
+			probes[len(probes)-1].Ports = ports
+		case "match":
+			m, err := parseMatchLine(rest)
+			if err != nil {
+				return nil, fmt.Errorf("%w: line %d: %s", ErrInvalidProbe, line, err)
+			}
+			probes[len(probes)-1].Matches = append(probes[len(probes)-1].Matches, m)
+		default:
+			return nil, fmt.Errorf("%w: line %d: unknown directive %q", ErrInvalidProbe, line, directive)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return probes, nil
+}
+
+// parseProbeLine parses `<name> q|<payload>|`
+func parseProbeLine(s string) (Probe, error) {
+	name, rest, _ := strings.Cut(s, " ")
+	payload, rest, err := delimited(strings.TrimSpace(rest), 'q')
+	if err != nil {
+		return Probe{}, err
+	}
+	if name == "" || rest != "" {
+		return Probe{}, fmt.Errorf("expected Probe <name> q|<payload>|, got %q", s)
+	}
+
+	b, err := unescape(payload)
+	if err != nil {
+		return Probe{}, err
+	}
+	return Probe{Name: name, Payload: b}, nil
+}
+
+// parseMatchLine parses `<service> m|<regexp>|[flags] [v|<version>|]`
+func parseMatchLine(s string) (Match, error) {
+	service, rest, _ := strings.Cut(s, " ")
+	expr, rest, err := delimited(strings.TrimSpace(rest), 'm')
+	if err != nil {
+		return Match{}, err
+	}
+
+	flags, rest, _ := strings.Cut(rest, " ")
+	if strings.Trim(flags, "is") != "" {
+		return Match{}, fmt.Errorf("unknown regexp flags %q", flags)
+	}
+	if flags != "" {
+		expr = "(?" + flags + ")" + expr
+	}
+	pattern, err := regexp.Compile(expr)
+	if err != nil {
+		return Match{}, err
+	}
+
+	m := Match{Service: service, Pattern: pattern}
+	if rest = strings.TrimSpace(rest); rest != "" {
+		version, rest, err := delimited(rest, 'v')
+		if err != nil {
+			return Match{}, err
+		}
+		if rest != "" {
+			return Match{}, fmt.Errorf("unexpected %q after the version", rest)
+		}
+		m.Version = version
+	}
+	if service == "" {
+		return Match{}, errors.New("missing service name")
+	}
+	return m, nil
+}
+
+// delimited splits a value such as q|payload| into its content and what follows it.
+// The character after the prefix is the delimiter
+func delimited(s string, prefix byte) (string, string, error) {
+	if len(s) < 3 || s[0] != prefix {
+		return "", "", fmt.Errorf("expected %c<delimiter>...<delimiter>, got %q", prefix, s)
+	}
+	delim := s[1]
+	end := strings.IndexByte(s[2:], delim)
+	if end < 0 {
+		return "", "", fmt.Errorf("missing closing %q in %q", delim, s)
+	}
+	return s[2 : 2+end], s[2+end+1:], nil
+}
+
+// unescape decodes the \r, \n, \t, \0, \\ and \xHH escapes of a probe payload
+func unescape(s string) ([]byte, error) {
+	var b []byte
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b = append(b, s[i])
+			continue
+		}
+		if i+1 == len(s) {
+			return nil, fmt.Errorf("payload %q ends with a lone \\", s)
+		}
+		i++
+		switch s[i] {
+		case 'r':
+			b = append(b, '\r')
+		case 'n':
+			b = append(b, '\n')
+		case 't':
+			b = append(b, '\t')
+		case '0':
+			b = append(b, 0)
+		case '\\':
+			b = append(b, '\\')
+		case 'x':
+			if i+2 >= len(s) {
+				return nil, fmt.Errorf("payload %q has a short \\x escape", s)
+			}
+			v, err := strconv.ParseUint(s[i+1:i+3], 16, 8)
+			if err != nil {
+				return nil, fmt.Errorf("payload %q has an invalid \\x escape", s)
+			}
+			b = append(b, byte(v))
+			i += 2
+		default:
+			return nil, fmt.Errorf("payload %q has an unknown escape \\%c", s, s[i])
+		}
+	}
+	return b, nil
+}
+
+// match returns the service and version of the first match of the probe that recognises reply
+func (p Probe) match(reply []byte) (string, string, bool) {
+	reply = latin1(reply)
+	for _, m := range p.Matches {
+		idx := m.Pattern.FindSubmatchIndex(reply)
+		if idx == nil {
+			continue
+		}
+		version := m.Pattern.Expand(nil, []byte(m.Version), reply, idx)
+		return m.Service, printable(version), true
+	}
+	return "", "", false
+}
+
+// latin1 decodes every byte of b as the character of the same value. Go regexps match
+// UTF-8 text, where \xff is the character U+00FF rather than the byte 0xFF, so replies
+// are decoded this way for the patterns to match them byte by byte like nmap's do
+func latin1(b []byte) []byte {
+	if !slices.ContainsFunc(b, func(c byte) bool { return c >= utf8.RuneSelf }) {
+		return b
+	}
+	runes := make([]rune, len(b))
+	for i, c := range b {
+		runes[i] = rune(c)
+	}
+	return []byte(string(runes))
+}
+
+// matchesNullProbe reports whether a banner is recognised by one of the probes with no payload
+func matchesNullProbe(probes []Probe, banner []byte) bool {
+	if probes == nil {
+		probes = DefaultProbes()
+	}
+	for _, p := range probes {
+		if _, _, ok := p.match(banner); ok && len(p.Payload) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// detectService finds the service listening on an open port. The banner the service
+// sent on its own is matched against the probes with no payload, then the other
+// probes are sent on new connections to address, those listing the port first, until one matches
+func (s *Scanner) detectService(ctx context.Context, address string, port int, banner []byte) (string, string) {
+	probes := s.Probes
+	if probes == nil {
+		probes = DefaultProbes()
+	}
+
+	for _, p := range probes {
+		if len(p.Payload) > 0 || len(banner) == 0 {
+			continue
+		}
+		if service, version, ok := p.match(banner); ok {
+			return service, version
+		}
+	}
+
+	// A service that talked first is not going to understand the other probes
+	if len(banner) > 0 {
+		return "", ""
+	}
+
+	ordered := slices.Clone(probes)
+	slices.SortStableFunc(ordered, func(a, b Probe) int {
+		aPort, bPort := slices.Contains(a.Ports, port), slices.Contains(b.Ports, port)
+		switch {
+		case aPort && !bPort:
+			return -1
+		case bPort && !aPort:
+			return 1
+		}
+		return 0
+	})
+
+	for _, p := range ordered {
+		if len(p.Payload) == 0 {
+			continue
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		if service, version, ok := s.sendProbe(ctx, address, p); ok {
+			return service, version
+		}
+	}
+	return "", ""
+}
+
+// sendProbe sends the payload of p on a new connection and matches the reply
+func (s *Scanner) sendProbe(ctx context.Context, address string, p Probe) (string, string, bool) {
+	conn, err := s.dial(ctx, ProtocolTCP, address)
+	if err != nil {
+		return "", "", false
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write(p.Payload); err != nil {
+		return "", "", false
+	}
+
+	matched := func(b []byte) bool {
+		_, _, ok := p.match(b)
+		return ok
+	}
+	reply := readResponse(ctx, conn, s.BannerTimeout, s.BannerSize, matched)
+	return p.match(reply)
+}
@@ -0,0 +1,140 @@
+package scan_test
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nguyenanhhao221/pScan/scan"
+)
+
+func TestParseProbes(t *testing.T) {
+	const probes = `
+# comment
+Probe NULL q||
+match ssh m|^SSH-([\d.]+)-(\S+)| v|$2 (protocol $1)|
+
+Probe Hello q/HELLO\r\n\x00/
+ports 7000-7002,9000
+match hello m|^hi there|i v/greeter/
+match hello m|^hi|
+`
+	got, err := scan.ParseProbes(strings.NewReader(probes))
+	if err != nil {
+		t.Fatalf("Expect no error, got %q", err)
+	}
+
+	if len(got) != 2 {
+		t.Fatalf("Expect 2 probes, got %d", len(got))
+	}
+	if got[0].Name != "NULL" || len(got[0].Payload) != 0 || len(got[0].Matches) != 1 {
+		t.Errorf("Unexpected NULL probe %+v", got[0])
+	}
+	if got[1].Name != "Hello" || string(got[1].Payload) != "HELLO\r\n\x00" {
+		t.Errorf("Unexpected Hello probe %+v", got[1])
+	}
+	if len(got[1].Ports) != 4 || got[1].Ports[3] != 9000 {
+		t.Errorf("Expect ports 7000-7002,9000, got %v", got[1].Ports)
+	}
+
+	m := got[1].Matches[0]
+	if m.Service != "hello" || m.Version != "greeter" || !m.Pattern.MatchString("HI THERE") {
+		t.Errorf("Unexpected match %+v", m)
+	}
+}
+
+func TestParseProbesInvalid(t *testing.T) {
+	testCases := []struct {
+		name   string
+		probes string
+	}{
+		{"MatchBeforeProbe", "match ssh m|^SSH|"},
+		{"UnknownDirective", "Probe NULL q||\nfoo bar"},
+		{"MissingPayload", "Probe NULL"},
+		{"UnclosedPayload", "Probe NULL q|abc"},
+		{"BadEscape", "Probe X q|\\q|"},
+		{"BadHexEscape", "Probe X q|\\xZZ|"},
+		{"BadPorts", "Probe NULL q||\nports 0"},
+		{"BadRegexp", "Probe NULL q||\nmatch ssh m|^(SSH|"},
+		{"BadFlags", "Probe NULL q||\nmatch ssh m|^SSH|x"},
+		{"TrailingVersion", "Probe NULL q||\nmatch ssh m|^SSH| v|1| extra"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := scan.ParseProbes(strings.NewReader(tc.probes))
+			if !errors.Is(err, scan.ErrInvalidProbe) {
+				t.Errorf("Expect error %q, got %v", scan.ErrInvalidProbe, err)
+			}
+		})
+	}
+}
+
+func TestDefaultProbes(t *testing.T) {
+	probes := scan.DefaultProbes()
+	if len(probes) == 0 || probes[0].Name != "NULL" {
+		t.Errorf("Expect bundled probes starting with NULL, got %d probes", len(probes))
+	}
+}
+
+func TestServiceDetection(t *testing.T) {
+	httpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Server", "nginx/1.25.3")
+		w.Write([]byte("<html></html>"))
+	}))
+	defer httpServer.Close()
+	httpPort := httpServer.Listener.Addr().(*net.TCPAddr).Port
+
+	redisPort := serveFunc(t, func(conn net.Conn) {
+		line, err := bufio.NewReader(conn).ReadString('\n')
+		if err == nil && line == "PING\r\n" {
+			conn.Write([]byte("+PONG\r\n"))
+		}
+		time.Sleep(time.Second)
+	})
+
+	testCases := []struct {
+		name       string
+		port       int
+		expService string
+		expVersion string
+	}{
+		{"SSH", serve(t, "SSH-2.0-OpenSSH_9.6\r\n"), "ssh", "OpenSSH_9.6 (protocol 2.0)"},
+		{"SMTP", serve(t, "220 mail.example.com ESMTP Postfix\r\n"), "smtp", "Postfix"},
+		{"MySQL", serve(t, "\x4a\x00\x00\x00\x0a8.0.36\x00\x08\x00\x00\x00"), "mysql", "MySQL 8.0.36"},
+		{"MariaDB", serve(t, "\x4a\x00\x00\x00\x0a11.4.2-MariaDB\x00\x08\x00\x00\x00"), "mysql", "MariaDB 11.4.2"},
+		// MariaDB before 11 prefixes its version for clients that expect MySQL 5
+		{"MariaDBCompat", serve(t, "\x4a\x00\x00\x00\x0a5.5.5-10.6.12-MariaDB-log\x00\x08\x00\x00\x00"), "mysql", "MariaDB 10.6.12"},
+		{"HTTP", httpPort, "http", "nginx/1.25.3"},
+		{"Redis", redisPort, "redis", ""},
+		// IAC DO TERMINAL-TYPE
+		{"Telnet", serve(t, "\xff\xfd\x18"), "telnet", ""},
+		{"Unknown", serve(t, "hello\r\n"), "", ""},
+		{"Silent", serve(t, ""), "", ""},
+	}
+
+	hl := &scan.HostList{}
+	if err := hl.Add("127.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := scan.Options{Service: true, BannerTimeout: 200 * time.Millisecond}
+			res := scan.RunOptions(hl, []int{tc.port}, opts)
+			if len(res) != 1 || len(res[0].PortStates) != 1 {
+				t.Fatalf("Expected 1 port state, got %+v\n", res)
+			}
+
+			ps := res[0].PortStates[0]
+			if ps.Service != tc.expService || ps.Version != tc.expVersion {
+				t.Errorf("Expect service %q version %q, got %q %q\n", tc.expService, tc.expVersion, ps.Service, ps.Version)
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package scan
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+var ErrInvalidQuery = errors.New("invalid tag query")
+
+// TagQuery selects hosts by their tags. A query is made of tag names combined with
+// AND, OR, NOT and parentheses, e.g. "prod AND (web OR db) AND NOT legacy".
+// NOT binds tighter than AND, which binds tighter than OR. The operators are
+// case insensitive and "!" can be used in place of NOT
+type TagQuery struct {
+	expr  string
+	match func(tags []string) bool
+}
+
+// ParseTagQuery parses a tag query
+func ParseTagQuery(expr string) (*TagQuery, error) {
+	p := &tagParser{tokens: tokenizeTags(expr)}
+	if len(p.tokens) == 0 {
+		return nil, fmt.Errorf("%w: empty query", ErrInvalidQuery)
+	}
+	match, err := p.or()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q: %s", ErrInvalidQuery, expr, err)
+	}
+	if tok, ok := p.peek(); ok {
+		return nil, fmt.Errorf("%w: %q: unexpected %q", ErrInvalidQuery, expr, tok)
+	}
+	return &TagQuery{expr: expr, match: match}, nil
+}
+
+// Match reports whether a host with tags is selected by the query
+func (q *TagQuery) Match(tags []string) bool {
+	return q.match(tags)
+}
+
+func (q *TagQuery) String() string {
+	return q.expr
+}
+
+// tokenizeTags splits a query into parentheses, "!" and words
+func tokenizeTags(expr string) []string {
+	var tokens []string
+	var word strings.Builder
+	flush := func() {
+		if word.Len() > 0 {
+			tokens = append(tokens, word.String())
+			word.Reset()
+		}
+	}
+
+	for _, r := range expr {
+		switch r {
+		case '(', ')', '!':
+			flush()
+			tokens = append(tokens, string(r))
+		case ' ', '\t':
+			flush()
+		default:
+			word.WriteRune(r)
+		}
+	}
+	flush()
+	return tokens
+}
+
+// tagParser is a recursive descent parser over the tokens of a query
+type tagParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *tagParser) peek() (string, bool) {
+	if p.pos == len(p.tokens) {
+		return "", false
+	}
+	return p.tokens[p.pos], true
+}
+
+// accept consumes the next token if it is the operator op
+func (p *tagParser) accept(op string) bool {
+	tok, ok := p.peek()
+	if ok && strings.EqualFold(tok, op) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *tagParser) or() (func([]string) bool, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("OR") {
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(tags []string) bool { return l(tags) || right(tags) }
+	}
+	return left, nil
+}
+
+func (p *tagParser) and() (func([]string) bool, error) {
+	left, err := p.not()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("AND") {
+		right, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(tags []string) bool { return l(tags) && right(tags) }
+	}
+	return left, nil
+}
+
+func (p *tagParser) not() (func([]string) bool, error) {
+	if p.accept("NOT") || p.accept("!") {
+		operand, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		return func(tags []string) bool { return !operand(tags) }, nil
+	}
+	return p.term()
+}
+
+func (p *tagParser) term() (func([]string) bool, error) {
+	tok, ok := p.peek()
+	if !ok {
+		return nil, errors.New("unexpected end of query")
+	}
+
+	switch {
+	case tok == "(":
+		p.pos++
+		inner, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(")") {
+			return nil, errors.New("missing )")
+		}
+		return inner, nil
+	case tok == ")", strings.EqualFold(tok, "AND"), strings.EqualFold(tok, "OR"):
+		return nil, fmt.Errorf("unexpected %q", tok)
+	}
+
+	p.pos++
+	return func(tags []string) bool { return slices.Contains(tags, tok) }, nil
+}
@@ -0,0 +1,52 @@
+package scan_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/nguyenanhhao221/pScan/scan"
+)
+
+func TestTagQuery(t *testing.T) {
+	testCases := []struct {
+		name   string
+		query  string
+		tags   []string
+		exp    bool
+		expErr error
+	}{
+		{name: "Tag", query: "prod", tags: []string{"web", "prod"}, exp: true},
+		{name: "MissingTag", query: "prod", tags: []string{"web"}, exp: false},
+		{name: "And", query: "prod AND web", tags: []string{"web", "prod"}, exp: true},
+		{name: "AndMissing", query: "prod and db", tags: []string{"web", "prod"}, exp: false},
+		{name: "Or", query: "db OR web", tags: []string{"web"}, exp: true},
+		{name: "Not", query: "NOT legacy", tags: nil, exp: true},
+		{name: "Bang", query: "prod AND !legacy", tags: []string{"prod", "legacy"}, exp: false},
+		{name: "Precedence", query: "db OR web AND NOT prod", tags: []string{"db", "prod"}, exp: true},
+		{name: "Parentheses", query: "(db OR web) AND NOT prod", tags: []string{"db", "prod"}, exp: false},
+		{name: "Nested", query: "prod AND (web OR (db AND !replica))", tags: []string{"prod", "db"}, exp: true},
+		{name: "Empty", query: " ", expErr: scan.ErrInvalidQuery},
+		{name: "DanglingOperator", query: "prod AND", expErr: scan.ErrInvalidQuery},
+		{name: "MissingOperator", query: "prod web", expErr: scan.ErrInvalidQuery},
+		{name: "UnbalancedOpen", query: "(prod OR web", expErr: scan.ErrInvalidQuery},
+		{name: "UnbalancedClose", query: "prod)", expErr: scan.ErrInvalidQuery},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			q, err := scan.ParseTagQuery(tc.query)
+			if tc.expErr != nil {
+				if !errors.Is(err, tc.expErr) {
+					t.Errorf("Expect error %q, got %v", tc.expErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expect no error, got %q", err)
+			}
+			if got := q.Match(tc.tags); got != tc.exp {
+				t.Errorf("Expect %q to match %v: %t, got %t", tc.query, tc.tags, tc.exp, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package scan
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// DefaultMaxExpand is the largest number of addresses a single target expression
+// may expand to when Options.MaxExpand is not set, the size of an IPv4 /16
+const DefaultMaxExpand = 1 << 16
+
+var (
+	ErrInvalidTarget  = errors.New("invalid target")
+	ErrTargetTooLarge = errors.New("target expands to too many addresses")
+)
+
+// ExpandTarget resolves a host list entry into the addresses it stands for.
+// An entry can be a CIDR block ("10.0.0.0/24"), an address range either as
+// two full addresses ("10.0.0.10-10.0.0.50") or with only the last IPv4 octet
+// ("192.168.1.10-50"), or anything else, such as a host name, which is
+// returned unchanged. It fails with ErrTargetTooLarge when the entry would
+// expand to more than limit addresses
+func ExpandTarget(target string, limit int) ([]string, error) {
+	if limit <= 0 {
+		limit = DefaultMaxExpand
+	}
+
+	if strings.Contains(target, "/") {
+		prefix, err := netip.ParsePrefix(target)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q: %s", ErrInvalidTarget, target, err)
+		}
+		prefix = prefix.Masked()
+
+		hostBits := prefix.Addr().BitLen() - prefix.Bits()
+		if hostBits >= 63 || 1<<hostBits > limit {
+			return nil, fmt.Errorf("%w: %q is larger than %d addresses", ErrTargetTooLarge, target, limit)
+		}
+
+		addrs := make([]string, 0, 1<<hostBits)
+		for a := prefix.Addr(); a.IsValid() && prefix.Contains(a); a = a.Next() {
+			addrs = append(addrs, a.String())
+		}
+		return addrs, nil
+	}
+
+	lo, hi, ok := strings.Cut(target, "-")
+	if !ok {
+		return []string{target}, nil
+	}
+	start, err := netip.ParseAddr(lo)
+	if err != nil {
+		// Not an address range, just a host name containing a dash
+		return []string{target}, nil
+	}
+
+	end, err := rangeEnd(start, hi)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q: %s", ErrInvalidTarget, target, err)
+	}
+	if end.Less(start) {
+		return nil, fmt.Errorf("%w: %q starts after it ends", ErrInvalidTarget, target)
+	}
+
+	var addrs []string
+	for a := start; a.IsValid() && !end.Less(a); a = a.Next() {
+		if len(addrs) == limit {
+			return nil, fmt.Errorf("%w: %q is larger than %d addresses", ErrTargetTooLarge, target, limit)
+		}
+		addrs = append(addrs, a.String())
+	}
+	return addrs, nil
+}
+
+// rangeEnd parses the end of an address range, either a full address of the
+// same family as start or, for IPv4, the value of the last octet
+func rangeEnd(start netip.Addr, hi string) (netip.Addr, error) {
+	if end, err := netip.ParseAddr(hi); err == nil {
+		if end.Is4() != start.Is4() {
+			return netip.Addr{}, errors.New("range mixes IPv4 and IPv6 addresses")
+		}
+		return end, nil
+	}
+
+	if !start.Is4() {
+		return netip.Addr{}, fmt.Errorf("%q is not an IPv6 address", hi)
+	}
+	octet, err := strconv.Atoi(hi)
+	if err != nil || octet < 0 || octet > 255 {
+		return netip.Addr{}, fmt.Errorf("%q is not an address or an octet", hi)
+	}
+	b := start.As4()
+	b[3] = byte(octet)
+	return netip.AddrFrom4(b), nil
+}
+
+// target is a single address or host to scan along with the host list entry it came from
+type target struct {
+	entry string
+	host  string
+}
+
+// expandTargets expands every entry of the host list, dropping addresses
+// already produced by an earlier entry
+func expandTargets(hosts []string, limit int) ([]target, error) {
+	var targets []target
+	seen := map[string]bool{}
+	for _, entry := range hosts {
+		addrs, err := ExpandTarget(entry, limit)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range addrs {
+			if seen[a] {
+				continue
+			}
+			seen[a] = true
+			targets = append(targets, target{entry: entry, host: a})
+		}
+	}
+	return targets, nil
+}
@@ -0,0 +1,83 @@
+package scan_test
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/nguyenanhhao221/pScan/scan"
+)
+
+func TestExpandTarget(t *testing.T) {
+	testCases := []struct {
+		name   string
+		target string
+		limit  int
+		exp    []string
+		expErr error
+	}{
+		{name: "HostName", target: "localhost", exp: []string{"localhost"}},
+		{name: "HostNameWithDash", target: "web-1.example.com", exp: []string{"web-1.example.com"}},
+		{name: "Address", target: "10.0.0.1", exp: []string{"10.0.0.1"}},
+		{name: "CIDR", target: "10.0.0.0/30", exp: []string{"10.0.0.0", "10.0.0.1", "10.0.0.2", "10.0.0.3"}},
+		{name: "CIDRNotMasked", target: "10.0.0.5/31", exp: []string{"10.0.0.4", "10.0.0.5"}},
+		{name: "CIDRSingle", target: "10.0.0.7/32", exp: []string{"10.0.0.7"}},
+		{name: "CIDRIPv6", target: "2001:db8::/126", exp: []string{"2001:db8::", "2001:db8::1", "2001:db8::2", "2001:db8::3"}},
+		{name: "OctetRange", target: "192.168.1.10-12", exp: []string{"192.168.1.10", "192.168.1.11", "192.168.1.12"}},
+		{name: "FullRange", target: "10.0.0.254-10.0.1.1", exp: []string{"10.0.0.254", "10.0.0.255", "10.0.1.0", "10.0.1.1"}},
+		{name: "IPv6Range", target: "2001:db8::1-2001:db8::2", exp: []string{"2001:db8::1", "2001:db8::2"}},
+		{name: "CIDRTooLarge", target: "10.0.0.0/8", expErr: scan.ErrTargetTooLarge},
+		{name: "CIDRAboveLimit", target: "10.0.0.0/24", limit: 100, expErr: scan.ErrTargetTooLarge},
+		{name: "RangeAboveLimit", target: "10.0.0.1-200", limit: 100, expErr: scan.ErrTargetTooLarge},
+		{name: "IPv6CIDRTooLarge", target: "2001:db8::/32", expErr: scan.ErrTargetTooLarge},
+		{name: "InvalidCIDR", target: "10.0.0.0/33", expErr: scan.ErrInvalidTarget},
+		{name: "ReversedRange", target: "192.168.1.50-10", expErr: scan.ErrInvalidTarget},
+		{name: "InvalidOctet", target: "192.168.1.10-256", expErr: scan.ErrInvalidTarget},
+		{name: "MixedFamilies", target: "10.0.0.1-2001:db8::1", expErr: scan.ErrInvalidTarget},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			addrs, err := scan.ExpandTarget(tc.target, tc.limit)
+			if tc.expErr != nil {
+				if !errors.Is(err, tc.expErr) {
+					t.Errorf("Expect error: %q, got %q instead", tc.expErr, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Expect no error, got error: %q", err)
+			}
+
+			if !slices.Equal(tc.exp, addrs) {
+				t.Errorf("Expect: %v, got %v\n", tc.exp, addrs)
+			}
+		})
+	}
+}
+
+func TestRunExpandsTargets(t *testing.T) {
+	hl := &scan.HostList{}
+	for _, host := range []string{"127.0.0.0/31", "127.0.0.1"} {
+		if err := hl.Add(host); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	res := scan.Run(hl, []int{})
+
+	// 127.0.0.1 is already part of the CIDR block so it is only scanned once
+	exp := []scan.Results{
+		{Host: "127.0.0.0", Target: "127.0.0.0/31"},
+		{Host: "127.0.0.1", Target: "127.0.0.0/31"},
+	}
+	if len(res) != len(exp) {
+		t.Fatalf("Expected %d results, got %d instead\n", len(exp), len(res))
+	}
+	for i := range exp {
+		if res[i].Host != exp[i].Host || res[i].Target != exp[i].Target {
+			t.Errorf("Expect %s (%s), got %s (%s)\n", exp[i].Host, exp[i].Target, res[i].Host, res[i].Target)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package scan
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	// DefaultTimeout is how long a connection attempt may take before the RTT of its host is known
+	DefaultTimeout = 1 * time.Second
+	// MinTimeout and MaxTimeout bound the timeouts derived from the RTT of a host
+	MinTimeout = 100 * time.Millisecond
+	MaxTimeout = 10 * time.Second
+	// DefaultRetries is the number of retries the scan command makes for probes that time out
+	DefaultRetries = 1
+)
+
+// rttEstimator tracks the round-trip time of a host the way TCP does (RFC 6298),
+// from the time connections take to be accepted or refused
+type rttEstimator struct {
+	mu      sync.Mutex
+	srtt    time.Duration
+	rttvar  time.Duration
+	samples int
+}
+
+// add records the time a connection attempt took to get an answer
+func (e *rttEstimator) add(rtt time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.samples == 0 {
+		e.srtt, e.rttvar = rtt, rtt/2
+	} else {
+		diff := e.srtt - rtt
+		if diff < 0 {
+			diff = -diff
+		}
+		e.rttvar = (3*e.rttvar + diff) / 4
+		e.srtt = (7*e.srtt + rtt) / 8
+	}
+	e.samples++
+}
+
+// timeout returns how long to wait for an answer, DefaultTimeout until the first sample
+func (e *rttEstimator) timeout() time.Duration {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.samples == 0 {
+		return DefaultTimeout
+	}
+	return min(max(e.srtt+4*e.rttvar, MinTimeout), MaxTimeout)
+}
+
+// connect dials the port of a probe, giving up after Timeout, or after the timeout
+// derived from the RTT of the host when it is zero. Attempts that time out are made
+// again up to Retries times, with twice the adaptive timeout each time, each retry
+// waiting on pace like any other probe to the host. A proxy only
+// gets Timeout, the RTT of the host is not what it waits on and it has its own timeout.
+// It returns how long the host took to accept or refuse the connection, zero without an answer
+func (s *Scanner) connect(ctx context.Context, network, address string, rtt *rttEstimator, pace func(context.Context) error) (net.Conn, time.Duration, error) {
+	timeout := s.Timeout
+	if timeout <= 0 && !s.proxied() {
+		timeout = rtt.timeout()
+	}
+
+	for attempt := 0; ; attempt++ {
+		dialCtx, cancel := ctx, context.CancelFunc(func() {})
+		if timeout > 0 {
+			dialCtx, cancel = context.WithTimeout(ctx, timeout)
+		}
+		start := time.Now()
+		conn, err := s.dial(dialCtx, network, address)
+		elapsed := time.Since(start)
+		cancel()
+
+		// Both an accepted and a refused connection took a round trip to the host
+		if err == nil || errors.Is(err, syscall.ECONNREFUSED) {
+			rtt.add(elapsed)
+			return conn, elapsed, err
+		}
+		if attempt >= s.Retries || ctx.Err() != nil || !timedOut(err) {
+			return conn, 0, err
+		}
+		if s.Timeout <= 0 && timeout > 0 {
+			timeout = min(2*timeout, MaxTimeout)
+		}
+		if err := pace(ctx); err != nil {
+			return nil, 0, err
+		}
+	}
+}
+
+// timedOut reports whether a dial or a read failed because no answer came back in time
+func timedOut(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
@@ -0,0 +1,158 @@
+package scan_test
+
+import (
+	"context"
+	"net"
+	"os"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/nguyenanhhao221/pScan/scan"
+)
+
+// timeoutDialer records how long each connection attempt was given. The first
+// timeouts dials time out, the following ones are refused
+type timeoutDialer struct {
+	timeouts int
+
+	mu     sync.Mutex
+	budget []time.Duration
+}
+
+func (d *timeoutDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return nil, os.ErrNoDeadline
+	}
+	d.budget = append(d.budget, time.Until(deadline))
+	if len(d.budget) <= d.timeouts {
+		return nil, &net.OpError{Op: "dial", Net: network, Err: os.ErrDeadlineExceeded}
+	}
+	return nil, &net.OpError{Op: "dial", Net: network, Err: syscall.ECONNREFUSED}
+}
+
+func TestRunAdaptiveTimeout(t *testing.T) {
+	d := &timeoutDialer{}
+	s := &scan.Scanner{Options: scan.Options{Workers: 1}, Dialer: d}
+	if _, err := s.Run(context.Background(), &scan.HostList{Hosts: []string{"127.0.0.1"}}, []int{1, 2, 3}); err != nil {
+		t.Fatalf("Expect no error, got %q", err)
+	}
+
+	if len(d.budget) != 3 {
+		t.Fatalf("Expect 3 dials, got %d", len(d.budget))
+	}
+	// The first probe knows nothing of the host, the others know it answers right away
+	if b := d.budget[0]; b > scan.DefaultTimeout || b < scan.DefaultTimeout-100*time.Millisecond {
+		t.Errorf("Expect the first dial to get about %s, got %s", scan.DefaultTimeout, b)
+	}
+	for i, b := range d.budget[1:] {
+		if b > scan.MinTimeout || b < scan.MinTimeout/2 {
+			t.Errorf("Expect dial %d to get about %s, got %s", i+1, scan.MinTimeout, b)
+		}
+	}
+}
+
+func TestRunRetries(t *testing.T) {
+	testCases := []struct {
+		name      string
+		opts      scan.Options
+		timeouts  int
+		expDials  int
+		expState  scan.State
+		expBudget []time.Duration
+	}{
+		{
+			name:     "NoRetry",
+			timeouts: 1,
+			expDials: 1,
+			expState: scan.StateFiltered,
+		},
+		{
+			name:     "AnsweredOnRetry",
+			opts:     scan.Options{Retries: 2},
+			timeouts: 1,
+			expDials: 2,
+			expState: scan.StateClosed,
+		},
+		{
+			// The adaptive timeout doubles on every retry
+			name:      "RetriesExhausted",
+			opts:      scan.Options{Retries: 2},
+			timeouts:  5,
+			expDials:  3,
+			expState:  scan.StateFiltered,
+			expBudget: []time.Duration{scan.DefaultTimeout, 2 * scan.DefaultTimeout, 4 * scan.DefaultTimeout},
+		},
+		{
+			// A fixed timeout stays the same
+			name:      "FixedTimeout",
+			opts:      scan.Options{Retries: 2, Timeout: 3 * time.Second},
+			timeouts:  5,
+			expDials:  3,
+			expState:  scan.StateFiltered,
+			expBudget: []time.Duration{3 * time.Second, 3 * time.Second, 3 * time.Second},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := &timeoutDialer{timeouts: tc.timeouts}
+			s := &scan.Scanner{Options: tc.opts, Dialer: d}
+			res, err := s.Run(context.Background(), &scan.HostList{Hosts: []string{"127.0.0.1"}}, []int{22})
+			if err != nil {
+				t.Fatalf("Expect no error, got %q", err)
+			}
+
+			if len(d.budget) != tc.expDials {
+				t.Errorf("Expect %d dials, got %d", tc.expDials, len(d.budget))
+			}
+			if ps := res[0].PortStates[0]; ps.State != tc.expState {
+				t.Errorf("Expect port %s, got %s (%s)", tc.expState, ps.State, ps.Reason)
+			}
+			for i, exp := range tc.expBudget {
+				if b := d.budget[i]; b > exp || b < exp-100*time.Millisecond {
+					t.Errorf("Expect dial %d to get about %s, got %s", i, exp, b)
+				}
+			}
+		})
+	}
+}
+
+func TestRunProxyTimeout(t *testing.T) {
+	testCases := []struct {
+		name string
+		opts scan.Options
+		exp  time.Duration
+	}{
+		// The proxy keeps its own timeout rather than the one derived from the RTT
+		{name: "Adaptive", exp: scan.DefaultProxyTimeout},
+		{name: "Fixed", opts: scan.Options{Timeout: 2 * time.Second}, exp: 2 * time.Second},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			d, err := scan.NewProxyDialer("socks5://127.0.0.1:1080")
+			if err != nil {
+				t.Fatal(err)
+			}
+			forward := &timeoutDialer{}
+			d.Forward = forward
+			s := &scan.Scanner{Options: tc.opts, Dialer: d}
+			if _, err := s.Run(context.Background(), &scan.HostList{Hosts: []string{"127.0.0.1"}}, []int{22}); err != nil {
+				t.Fatalf("Expect no error, got %q", err)
+			}
+
+			if len(forward.budget) != 1 {
+				t.Fatalf("Expect 1 dial, got %d", len(forward.budget))
+			}
+			if b := forward.budget[0]; b > tc.exp || b < tc.exp-100*time.Millisecond {
+				t.Errorf("Expect the proxy to get about %s, got %s", tc.exp, b)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package scan
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/netip"
+	"time"
+)
+
+// DefaultTLSExpiryDays is how close to its expiry a certificate gets a warning
+// when Options.TLSExpiryDays is not set
+const DefaultTLSExpiryDays = 30
+
+// TLSInfo describes the TLS handshake made with an open port and the certificate it presented
+type TLSInfo struct {
+	Version     string
+	CipherSuite string
+	Subject     string
+	SANs        []string
+	Issuer      string
+	NotBefore   time.Time
+	NotAfter    time.Time
+	SelfSigned  bool
+	// Warnings lists the problems found with the certificate, such as its upcoming expiry
+	Warnings []string
+}
+
+// inspectTLS makes a TLS handshake with an open port on a new connection.
+// It returns nil when the port does not speak TLS. The certificate is not
+// verified, the point is to report on it whatever it is
+func (s *Scanner) inspectTLS(ctx context.Context, host, addr string, port int) *TLSInfo {
+	timeout := s.BannerTimeout
+	if timeout <= 0 {
+		timeout = DefaultBannerTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout+1*time.Second)
+	defer cancel()
+
+	cfg := &tls.Config{InsecureSkipVerify: true}
+	// Send the host name so servers with several certificates present the right one
+	if _, err := netip.ParseAddr(host); err != nil {
+		cfg.ServerName = host
+	}
+	rawConn, err := s.dial(ctx, ProtocolTCP, dialAddress(host, addr, port))
+	if err != nil {
+		return nil
+	}
+	conn := tls.Client(rawConn, cfg)
+	defer conn.Close()
+	if err := conn.HandshakeContext(ctx); err != nil {
+		return nil
+	}
+
+	state := conn.ConnectionState()
+	if len(state.PeerCertificates) == 0 {
+		return nil
+	}
+	cert := state.PeerCertificates[0]
+
+	info := &TLSInfo{
+		Version:     tls.VersionName(state.Version),
+		CipherSuite: tls.CipherSuiteName(state.CipherSuite),
+		Subject:     cert.Subject.String(),
+		SANs:        certNames(cert),
+		Issuer:      cert.Issuer.String(),
+		NotBefore:   cert.NotBefore,
+		NotAfter:    cert.NotAfter,
+		SelfSigned:  selfSigned(cert),
+	}
+
+	days := s.TLSExpiryDays
+	if days <= 0 {
+		days = DefaultTLSExpiryDays
+	}
+	now := time.Now()
+	switch {
+	case now.After(cert.NotAfter):
+		info.Warnings = append(info.Warnings, fmt.Sprintf("certificate expired on %s", cert.NotAfter.Format(time.DateOnly)))
+	case now.Before(cert.NotBefore):
+		info.Warnings = append(info.Warnings, fmt.Sprintf("certificate is not valid before %s", cert.NotBefore.Format(time.DateOnly)))
+	case cert.NotAfter.Sub(now) < time.Duration(days)*24*time.Hour:
+		info.Warnings = append(info.Warnings, fmt.Sprintf("certificate expires in %d days on %s",
+			int(cert.NotAfter.Sub(now).Hours()/24), cert.NotAfter.Format(time.DateOnly)))
+	}
+	if info.SelfSigned {
+		info.Warnings = append(info.Warnings, "certificate is self-signed")
+	}
+	return info
+}
+
+// selfSigned reports whether cert is issued by its own subject and signed by its own key.
+// CheckSignatureFrom is no use here, it wants the issuer to be a CA and most self-signed
+// certificates of devices are not
+func selfSigned(cert *x509.Certificate) bool {
+	return bytes.Equal(cert.RawIssuer, cert.RawSubject) &&
+		cert.CheckSignature(cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature) == nil
+}
+
+// certNames lists the DNS names and IP addresses a certificate is valid for
+func certNames(cert *x509.Certificate) []string {
+	names := append([]string{}, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		names = append(names, ip.String())
+	}
+	return names
+}
@@ -0,0 +1,143 @@
+package scan_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nguyenanhhao221/pScan/scan"
+)
+
+func TestTLS(t *testing.T) {
+	// httptest uses a self-signed certificate valid for example.com and 127.0.0.1 until 2084
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	tlsPort := server.Listener.Addr().(*net.TCPAddr).Port
+	plainPort := serve(t, "")
+
+	hl := &scan.HostList{}
+	if err := hl.Add("127.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Warn about any certificate expiring in the next 100 years
+	opts := scan.Options{TLS: true, TLSExpiryDays: 365 * 100, BannerTimeout: 200 * time.Millisecond}
+	res := scan.RunOptions(hl, []int{tlsPort, plainPort}, opts)
+	if len(res) != 1 || len(res[0].PortStates) != 2 {
+		t.Fatalf("Expected 2 port states, got %+v\n", res)
+	}
+
+	info := res[0].PortStates[0].TLS
+	if info == nil {
+		t.Fatalf("Expect TLS details for port %d\n", tlsPort)
+	}
+	if !strings.HasPrefix(info.Version, "TLS 1.") || info.CipherSuite == "" {
+		t.Errorf("Expect negotiated version and cipher, got %q %q\n", info.Version, info.CipherSuite)
+	}
+	if !strings.Contains(info.Subject, "Acme Co") || !strings.Contains(info.Issuer, "Acme Co") {
+		t.Errorf("Expect subject and issuer Acme Co, got %q and %q\n", info.Subject, info.Issuer)
+	}
+	if !slices.Contains(info.SANs, "example.com") || !slices.Contains(info.SANs, "127.0.0.1") {
+		t.Errorf("Expect SANs example.com and 127.0.0.1, got %v\n", info.SANs)
+	}
+	if !info.SelfSigned {
+		t.Errorf("Expect certificate to be self-signed\n")
+	}
+	if info.NotAfter.Year() != 2084 {
+		t.Errorf("Expect certificate to expire in 2084, got %s\n", info.NotAfter)
+	}
+	if len(info.Warnings) != 2 || !strings.HasPrefix(info.Warnings[0], "certificate expires in") || info.Warnings[1] != "certificate is self-signed" {
+		t.Errorf("Expect expiry and self-signed warnings, got %q\n", info.Warnings)
+	}
+
+	if res[0].PortStates[1].TLS != nil {
+		t.Errorf("Expect no TLS details for plain port %d, got %+v\n", plainPort, res[0].PortStates[1].TLS)
+	}
+}
+
+// newCert returns a certificate for 127.0.0.1 that is not a CA, signed by parent and
+// its key, or by itself when parent is nil
+func newCert(t *testing.T, subject string, parent *tls.Certificate) tls.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: subject},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(365 * 24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	issuer, signer := template, any(key)
+	if parent != nil {
+		issuer, signer = parent.Leaf, parent.PrivateKey
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, issuer, &key.PublicKey, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+func TestTLSSelfSigned(t *testing.T) {
+	device := newCert(t, "device", nil)
+	issuer := newCert(t, "Test issuer", nil)
+
+	testCases := []struct {
+		name string
+		cert tls.Certificate
+		exp  bool
+	}{
+		// Devices usually present a self-signed certificate that is not a CA
+		{name: "Leaf", cert: device, exp: true},
+		{name: "Issued", cert: newCert(t, "web", &issuer), exp: false},
+	}
+
+	hl := &scan.HostList{}
+	if err := hl.Add("127.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+			server.TLS = &tls.Config{Certificates: []tls.Certificate{tc.cert}}
+			server.StartTLS()
+			defer server.Close()
+
+			opts := scan.Options{TLS: true, BannerTimeout: 200 * time.Millisecond}
+			res := scan.RunOptions(hl, []int{server.Listener.Addr().(*net.TCPAddr).Port}, opts)
+			info := res[0].PortStates[0].TLS
+			if info == nil {
+				t.Fatal("Expect TLS details")
+			}
+			if info.SelfSigned != tc.exp {
+				t.Errorf("Expect self-signed %v, got %v", tc.exp, info.SelfSigned)
+			}
+			if warned := slices.Contains(info.Warnings, "certificate is self-signed"); warned != tc.exp {
+				t.Errorf("Expect self-signed warning %v, got %q", tc.exp, info.Warnings)
+			}
+		})
+	}
+}
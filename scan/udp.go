@@ -0,0 +1,126 @@
+package scan
+
+import (
+	"context"
+	"errors"
+	"net"
+	"syscall"
+	"time"
+)
+
+// udpPayload is a request that makes a UDP service reply, most UDP services
+// stay silent unless they receive a packet in their own protocol
+type udpPayload struct {
+	service string
+	data    []byte
+}
+
+// udpPayloads holds the payload sent to well known UDP ports, other ports get an empty datagram
+var udpPayloads = map[int]udpPayload{
+	// Standard query for the NS records of the root zone
+	53: {service: "dns", data: []byte{
+		0x12, 0x34, 0x01, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x02, 0x00, 0x01,
+	}},
+	// NTPv3 client request, the rest of the 48 bytes packet is zero
+	123: {service: "ntp", data: append([]byte{0x1b}, make([]byte, 47)...)},
+	// SNMPv1 get-request for sysDescr.0 with the "public" community
+	161: {service: "snmp", data: []byte{
+		0x30, 0x29, 0x02, 0x01, 0x00, 0x04, 0x06, 0x70, 0x75, 0x62, 0x6c, 0x69, 0x63, 0xa0, 0x1c, 0x02,
+		0x04, 0x00, 0x00, 0x00, 0x01, 0x02, 0x01, 0x00, 0x02, 0x01, 0x00, 0x30, 0x0e, 0x30, 0x0c, 0x06,
+		0x08, 0x2b, 0x06, 0x01, 0x02, 0x01, 0x01, 0x01, 0x00, 0x05, 0x00,
+	}},
+}
+
+// scanUDPPort sends a payload to a single UDP port and classifies the port from
+// the reply. A reply means the port is open and an ICMP port unreachable error
+// means it is closed, but silence cannot tell an open port from a filtered one.
+// The reply is waited for as long as a connection attempt would be, and the
+// payload is sent again up to Retries times while no answer comes back, each
+// time after waiting on pace
+func (s *Scanner) scanUDPPort(ctx context.Context, host, addr string, port int, rtt *rttEstimator, pace func(context.Context) error) PortState {
+	p := PortState{Port: port, Protocol: ProtocolUDP}
+	address := dialAddress(host, addr, port)
+	conn, err := s.dial(ctx, ProtocolUDP, address)
+	if err != nil {
+		if ctx.Err() != nil {
+			p.Canceled = true
+			return p
+		}
+		p.State, p.Reason = classifyUDP(err)
+		return p
+	}
+	defer conn.Close()
+
+	payload := udpPayloads[port]
+	timeout := s.Timeout
+	if timeout <= 0 {
+		timeout = rtt.timeout()
+	}
+	size := s.BannerSize
+	if size <= 0 {
+		size = DefaultBannerSize
+	}
+
+	// Unblock the read as soon as the scan is canceled
+	stop := context.AfterFunc(ctx, func() { conn.SetReadDeadline(time.Now()) })
+	defer stop()
+
+	// The ICMP error for a closed port is reported by the read on a connected socket
+	buf := make([]byte, size)
+	var n int
+	for attempt := 0; ; attempt++ {
+		if _, err = conn.Write(payload.data); err != nil {
+			p.State, p.Reason = classifyUDP(err)
+			return p
+		}
+		start := time.Now()
+		if err = conn.SetReadDeadline(start.Add(timeout)); err != nil {
+			p.State, p.Reason = StateError, err.Error()
+			return p
+		}
+		n, err = conn.Read(buf)
+		if n > 0 || errors.Is(err, syscall.ECONNREFUSED) {
+			p.Latency = time.Since(start)
+			rtt.add(p.Latency)
+		}
+		if n > 0 || attempt >= s.Retries || ctx.Err() != nil || !timedOut(err) {
+			break
+		}
+		if s.Timeout <= 0 {
+			timeout = min(2*timeout, MaxTimeout)
+		}
+		if err = pace(ctx); err != nil {
+			break
+		}
+	}
+	if err != nil && n == 0 {
+		if ctx.Err() != nil {
+			p.Canceled = true
+			return p
+		}
+		p.State, p.Reason = classifyUDP(err)
+		return p
+	}
+
+	p.State, p.Reason = StateOpen, "udp-response"
+	if s.Banner {
+		p.Banner = printable(buf[:n])
+	}
+	if s.Service {
+		p.Service = payload.service
+	}
+	return p
+}
+
+// classifyUDP maps an error from a UDP probe to a port state and the reason for it
+func classifyUDP(err error) (State, string) {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return StateClosed, "port-unreach"
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return StateOpenFiltered, "no-response"
+	}
+	return classify(err)
+}
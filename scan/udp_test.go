@@ -0,0 +1,207 @@
+package scan_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/nguyenanhhao221/pScan/scan"
+)
+
+// serveUDP listens on a local UDP port and sends reply back to every datagram,
+// an empty reply keeps the listener silent
+func serveUDP(t *testing.T, reply string) int {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", net.JoinHostPort("127.0.0.1", "0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 1024)
+		for {
+			_, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if reply != "" {
+				conn.WriteTo([]byte(reply), addr)
+			}
+		}
+	}()
+
+	return conn.LocalAddr().(*net.UDPAddr).Port
+}
+
+func TestRunUDP(t *testing.T) {
+	// Closing the listener makes the port answer with ICMP port unreachable
+	closedConn, err := net.ListenPacket("udp", net.JoinHostPort("127.0.0.1", "0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := closedConn.LocalAddr().(*net.UDPAddr).Port
+	closedConn.Close()
+
+	testCases := []struct {
+		name      string
+		port      int
+		expState  scan.State
+		expReason string
+		expBanner string
+	}{
+		{name: "Open", port: serveUDP(t, "pong\n"), expState: scan.StateOpen, expReason: "udp-response", expBanner: "pong"},
+		{name: "Silent", port: serveUDP(t, ""), expState: scan.StateOpenFiltered, expReason: "no-response"},
+		{name: "Closed", port: closed, expState: scan.StateClosed, expReason: "port-unreach"},
+	}
+
+	hl := &scan.HostList{}
+	if err := hl.Add("127.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	var ports []int
+	for _, tc := range testCases {
+		ports = append(ports, tc.port)
+	}
+
+	opts := scan.Options{Protocol: scan.ProtocolUDP, Banner: true, Timeout: 200 * time.Millisecond}
+	res := scan.RunOptions(hl, ports, opts)
+	if len(res) != 1 || len(res[0].PortStates) != len(testCases) {
+		t.Fatalf("Expect 1 host with %d ports, got %v\n", len(testCases), res)
+	}
+
+	for i, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := res[0].PortStates[i]
+			if p.Protocol != scan.ProtocolUDP {
+				t.Errorf("Expect protocol %q, got %q\n", scan.ProtocolUDP, p.Protocol)
+			}
+			if p.State != tc.expState {
+				t.Errorf("Expect state %q, got %q\n", tc.expState, p.State)
+			}
+			if p.Reason != tc.expReason {
+				t.Errorf("Expect reason %q, got %q\n", tc.expReason, p.Reason)
+			}
+			if p.Banner != tc.expBanner {
+				t.Errorf("Expect banner %q, got %q\n", tc.expBanner, p.Banner)
+			}
+		})
+	}
+}
+
+func TestRunInvalidProtocol(t *testing.T) {
+	hl := &scan.HostList{}
+	if err := hl.Add("localhost"); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := scan.RunContext(context.Background(), hl, []int{53}, scan.Options{Protocol: "sctp"})
+	if !errors.Is(err, scan.ErrInvalidProtocol) {
+		t.Errorf("Expect error %q, got %q\n", scan.ErrInvalidProtocol, err)
+	}
+}
+
+// serveUDPLossy is like serveUDP but drops the first datagram, as a lossy network would
+func serveUDPLossy(t *testing.T, reply string) int {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", net.JoinHostPort("127.0.0.1", "0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 1024)
+		for n := 0; ; n++ {
+			_, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if n > 0 {
+				conn.WriteTo([]byte(reply), addr)
+			}
+		}
+	}()
+
+	return conn.LocalAddr().(*net.UDPAddr).Port
+}
+
+func TestRunUDPRetries(t *testing.T) {
+	hl := &scan.HostList{}
+	if err := hl.Add("127.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+
+	for retries, exp := range []scan.State{scan.StateOpenFiltered, scan.StateOpen} {
+		opts := scan.Options{Protocol: scan.ProtocolUDP, Retries: retries, Timeout: 100 * time.Millisecond}
+		res, err := scan.RunContext(context.Background(), hl, []int{serveUDPLossy(t, "pong")}, opts)
+		if err != nil {
+			t.Fatalf("Expect no error, got %q", err)
+		}
+		if ps := res[0].PortStates[0]; ps.State != exp {
+			t.Errorf("Expect port %s with %d retries, got %s", exp, retries, ps.State)
+		}
+	}
+}
+
+// serveUDPSlow listens on a local UDP port and replies to every datagram after delay
+func serveUDPSlow(t *testing.T, reply string, delay time.Duration) int {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", net.JoinHostPort("127.0.0.1", "0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 1024)
+		for {
+			_, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			time.AfterFunc(delay, func() { conn.WriteTo([]byte(reply), addr) })
+		}
+	}()
+
+	return conn.LocalAddr().(*net.UDPAddr).Port
+}
+
+func TestRunUDPTimeout(t *testing.T) {
+	hl := &scan.HostList{}
+	if err := hl.Add("127.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name string
+		opts scan.Options
+		exp  scan.State
+	}{
+		{name: "TooShort", opts: scan.Options{Timeout: 50 * time.Millisecond}, exp: scan.StateOpenFiltered},
+		{name: "LongEnough", opts: scan.Options{Timeout: 500 * time.Millisecond}, exp: scan.StateOpen},
+		// The first reply is waited for DefaultTimeout until the RTT of the host is known
+		{name: "Adaptive", exp: scan.StateOpen},
+		// Retries double the adaptive timeout, not a fixed one
+		{name: "FixedWithRetries", opts: scan.Options{Timeout: 40 * time.Millisecond, Retries: 2}, exp: scan.StateOpenFiltered},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.opts.Protocol = scan.ProtocolUDP
+			port := serveUDPSlow(t, "pong", 200*time.Millisecond)
+			res, err := scan.RunContext(context.Background(), hl, []int{port}, tc.opts)
+			if err != nil {
+				t.Fatalf("Expect no error, got %q", err)
+			}
+			if ps := res[0].PortStates[0]; ps.State != tc.exp {
+				t.Errorf("Expect port %s, got %s (%s)", tc.exp, ps.State, ps.Reason)
+			}
+		})
+	}
+}